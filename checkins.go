@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// How check-ins are detected
+type checkInMode int
+
+const (
+	// Every message in the study channel is a check-in
+	checkInModeMessages checkInMode = iota
+	// Check-ins come from /checkin, ✅ reactions and new threads, none of
+	// which need the privileged Message Content intent
+	checkInModeContentFree
+)
+
+func (m checkInMode) String() string {
+	if m == checkInModeContentFree {
+		return "content-free (slash commands, reactions and new threads)"
+	}
+	return "messages"
+}
+
+// Application flags showing the Message Content intent was granted
+const (
+	applicationFlagGatewayMessageContent        = 1 << 18
+	applicationFlagGatewayMessageContentLimited = 1 << 19
+)
+
+// Emoji the tracked user reacts with to check in in content-free mode
+const checkInReaction = "✅"
+
+var activeCheckInMode = checkInModeMessages
+
+// detectCheckInMode picks message-based check-ins if the bot was granted the
+// Message Content intent and falls back to content-free check-ins otherwise.
+func detectCheckInMode(s Session) checkInMode {
+	app, err := s.Application("@me")
+	if err != nil {
+		log.Printf("Error fetching application flags, assuming no Message Content intent: %v", err)
+		return checkInModeContentFree
+	}
+
+	if app.Flags&(applicationFlagGatewayMessageContent|applicationFlagGatewayMessageContentLimited) != 0 {
+		return checkInModeMessages
+	}
+	return checkInModeContentFree
+}
+
+// isTrackedUser reports whether check-ins from this user should be recorded.
+func isTrackedUser(u *discordgo.User) bool {
+	return u != nil && isTrackedUsername(u.Username)
+}
+
+func isTrackedUsername(username string) bool {
+	return slices.Contains(config.TrackedUsers, username)
+}
+
+// trackedOnly wraps a command handler so anyone else gets a private refusal
+// before handler runs, and before a deferred handler posts its placeholder.
+func trackedOnly(handler func(s Session, i *discordgo.InteractionCreate)) func(s Session, i *discordgo.InteractionCreate) {
+	return func(s Session, i *discordgo.InteractionCreate) {
+		if !isTrackedUser(interactionUser(i)) {
+			respondEphemeral(s, i, "❌ I'm not tracking check-ins for you.")
+			return
+		}
+		handler(s, i)
+	}
+}
+
+func messageReactionAdd(s Session, r *discordgo.MessageReactionAdd) {
+	if activeCheckInMode != checkInModeContentFree {
+		return
+	}
+	if !isCheckInChannel(r.ChannelID) || r.Emoji.Name != checkInReaction || r.Member == nil {
+		return
+	}
+	if !isTrackedUser(r.Member.User) {
+		return
+	}
+
+	// Only the user's own messages from today count, so reacting to older
+	// or other people's messages can't inflate streaks
+	message, err := s.ChannelMessage(r.ChannelID, r.MessageID)
+	if err != nil {
+		log.Printf("Error looking up message %s reacted to by %s: %v", r.MessageID, r.Member.User.Username, err)
+		return
+	}
+	if message.Author == nil || message.Author.ID != r.Member.User.ID || dayKey(message.Timestamp.Local()) != dayKey(time.Now()) {
+		return
+	}
+
+	_, err = recordCheckIn(context.Background(), CheckInRecorded{
+		UserID:    r.Member.User.ID,
+		Username:  r.Member.User.Username,
+		Source:    checkInSourceReaction,
+		ChannelID: r.ChannelID,
+		MessageID: r.MessageID,
+	})
+	if err != nil {
+		log.Printf("Error recording check-in from %s: %v", r.Member.User.Username, err)
+	}
+}
+
+func threadCreate(s Session, t *discordgo.ThreadCreate) {
+	if activeCheckInMode != checkInModeContentFree {
+		return
+	}
+	if !t.NewlyCreated || t.ParentID != config.StudyChannelID {
+		return
+	}
+
+	owner, err := s.User(t.OwnerID)
+	if err != nil {
+		log.Printf("Error looking up owner of thread %s: %v", t.ID, err)
+		return
+	}
+	if !isTrackedUser(owner) {
+		return
+	}
+
+	_, err = recordCheckIn(context.Background(), CheckInRecorded{
+		UserID:    owner.ID,
+		Username:  owner.Username,
+		Source:    checkInSourceThread,
+		ChannelID: t.ID,
+	})
+	if err != nil {
+		log.Printf("Error recording check-in from %s: %v", owner.Username, err)
+	}
+}
+
+// acknowledgeCheckIn reacts to the message a check-in came from: ✅ for
+// messages (reaction check-ins already have one) and 🌙 when it was outside
+// the project's check-in window.
+func acknowledgeCheckIn(ctx context.Context, s Session, e CheckInRecorded) {
+	if e.MessageID == "" {
+		return
+	}
+
+	if e.Source == checkInSourceMessage {
+		s.MessageReactionAdd(e.ChannelID, e.MessageID, checkInReaction, discordgo.WithContext(ctx))
+	}
+	if e.OutsideWindow {
+		s.MessageReactionAdd(e.ChannelID, e.MessageID, outsideWindowReaction, discordgo.WithContext(ctx))
+	}
+}
+
+// handleCheckInCommand runs deferred: recording a check-in runs every
+// subscriber, some of which call Discord. Only tracked users get here, see
+// trackedOnly.
+func handleCheckInCommand(ctx context.Context, s Session, i *discordgo.InteractionCreate) (string, error) {
+	user := interactionUser(i)
+
+	checkIn, err := recordCheckIn(ctx, CheckInRecorded{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Source:    checkInSourceCommand,
+		ChannelID: i.ChannelID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Public, so the channel sees the update like it would a message
+	content := fmt.Sprintf("%s <@%s> checked in", checkInReaction, user.ID)
+	options := i.ApplicationCommandData().Options
+	if len(options) > 0 {
+		content += ": " + options[0].StringValue()
+	}
+	if checkIn.OutsideWindow {
+		content += " " + outsideWindowReaction + " (outside check-in window)"
+	}
+	return content, nil
+}
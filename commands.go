@@ -0,0 +1,526 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Slash commands registered with Discord on startup
+var commands = []*discordgo.ApplicationCommand{
+	{
+		Name:        "checkin",
+		Description: "Record a study check-in",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "update",
+				Description: "What you worked on",
+			},
+		},
+	},
+	{
+		Name:        "project",
+		Description: "Manage the projects your check-ins count towards",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "switch",
+				Description: "Switch to a project, creating it if it doesn't exist",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "name",
+						Description: "Project name",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "List your projects",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "delete",
+				Description: "Move a project to the trash",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "name",
+						Description: "Project name",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "window",
+				Description: "Set the hours you check in on your active project, or clear them",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "start",
+						Description: "When the window opens, 24h HH:MM (leave both empty to clear)",
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "end",
+						Description: "When the window closes, 24h HH:MM",
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "transfer",
+				Description: "Hand your active project over to another member",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionUser,
+						Name:        "user",
+						Description: "New owner",
+						Required:    true,
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        "trash",
+		Description: "See and restore deleted projects",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "List your deleted projects",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "restore",
+				Description: "Restore a deleted project",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "id",
+						Description: "ID shown by /trash list",
+						Required:    true,
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        "metric",
+		Description: "Track numbers like words written or pages read on your active project",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "define",
+				Description: "Start tracking a new metric",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "name",
+						Description: "Metric name, e.g. words",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "unit",
+						Description: "Unit shown after amounts, e.g. km",
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "Show your metrics with this week's and all-time totals",
+			},
+		},
+	},
+	{
+		Name:        "log",
+		Description: "Log progress on a metric",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "metric",
+				Description: "Metric name",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionNumber,
+				Name:        "amount",
+				Description: "How much to add",
+				Required:    true,
+			},
+		},
+	},
+}
+
+// Handlers for each slash command, by command name
+var commandHandlers = map[string]func(s Session, i *discordgo.InteractionCreate){
+	"checkin": trackedOnly(deferred(handleCheckInCommand, false)),
+	"log":     trackedOnly(handleLogCommand),
+	"metric":  trackedOnly(handleMetricCommand),
+	"project": trackedOnly(handleProjectCommand),
+	"trash":   trackedOnly(handleTrashCommand),
+}
+
+func registerCommands(s Session, appID string) {
+	_, err := s.ApplicationCommandBulkOverwrite(appID, "", commands)
+	if err != nil {
+		log.Printf("Error registering slash commands: %v", err)
+	}
+}
+
+// Handlers for message component interactions, by the prefix of the
+// component's custom ID (the part before the colon)
+var componentHandlers = map[string]func(s Session, i *discordgo.InteractionCreate){
+	"reengage": handleReengagementButton,
+	"rest":     handleRestButton,
+}
+
+func interactionCreate(s Session, i *discordgo.InteractionCreate) {
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		handleCommand(s, i)
+	case discordgo.InteractionMessageComponent:
+		handleComponent(s, i)
+	}
+}
+
+func handleComponent(s Session, i *discordgo.InteractionCreate) {
+	customID := i.MessageComponentData().CustomID
+	prefix, _, _ := strings.Cut(customID, ":")
+	handler, ok := componentHandlers[prefix]
+	if !ok {
+		log.Printf("No handler for component %s", customID)
+		return
+	}
+	handler(s, i)
+}
+
+func handleCommand(s Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	handler, ok := commandHandlers[data.Name]
+	if !ok {
+		log.Printf("No handler for command /%s", data.Name)
+		return
+	}
+
+	// Enforce per-user cooldowns before doing any work
+	user := interactionUser(i)
+	path := commandPath(data)
+	allowed, wait := commandLimiter.allow(user.ID+":"+path, limitFor(path), time.Now())
+	if !allowed {
+		respondEphemeral(s, i, fmt.Sprintf("🐢 Slow down! You can use `/%s` again in %s.", path, wait.Truncate(time.Second)+time.Second))
+		log.Printf("Rate limited %s on /%s", user.Username, path)
+		return
+	}
+
+	handler(s, i)
+}
+
+// How long a deferred command may run before it's reported as failed. The
+// interaction token itself stays valid for 15 minutes.
+const deferredCommandTimeout = 2 * time.Minute
+
+// A command handler that may take longer than Discord's 3 second window to
+// respond. It returns the response content, or an error to report privately.
+// Once ctx is done the user has been told the command is taking too long, so
+// handlers should check it before committing any change and pass it to their
+// Discord calls with discordgo.WithContext.
+type deferredHandler func(ctx context.Context, s Session, i *discordgo.InteractionCreate) (string, error)
+
+// deferred adapts a slow handler for commandHandlers. It acknowledges the
+// interaction straight away ("bot is thinking"), runs handler in the
+// background with a timeout and then fills in the response. Errors and
+// timeouts replace the response with an ephemeral followup. Panics aren't
+// recovered: handlers unlock dbMu by hand, so carrying on could deadlock.
+func deferred(handler deferredHandler, ephemeral bool) func(s Session, i *discordgo.InteractionCreate) {
+	return func(s Session, i *discordgo.InteractionCreate) {
+		var flags discordgo.MessageFlags
+		if ephemeral {
+			flags = discordgo.MessageFlagsEphemeral
+		}
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Flags: flags},
+		})
+		if err != nil {
+			log.Printf("Error deferring interaction: %v", err)
+			return
+		}
+
+		go runDeferred(s, i, handler)
+	}
+}
+
+type deferredResult struct {
+	content string
+	err     error
+}
+
+func runDeferred(s Session, i *discordgo.InteractionCreate, handler deferredHandler) {
+	ctx, cancel := context.WithTimeout(context.Background(), deferredCommandTimeout)
+	defer cancel()
+
+	done := make(chan deferredResult, 1)
+	go func() {
+		content, err := handler(ctx, s, i)
+		done <- deferredResult{content, err}
+	}()
+
+	var result deferredResult
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		// The handler may have committed its change already and still be
+		// finishing up, so retrying could do it twice
+		result.err = fmt.Errorf("that's taking longer than expected. It may still go through, so check before trying again")
+	}
+
+	if result.err != nil {
+		reportDeferredFailure(s, i, result.err)
+		return
+	}
+
+	_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content:         &result.content,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		log.Printf("Error editing deferred response: %v", err)
+	}
+}
+
+// reportDeferredFailure swaps the placeholder response for a private error,
+// so failures aren't shown to the whole channel.
+func reportDeferredFailure(s Session, i *discordgo.InteractionCreate, failure error) {
+	log.Printf("/%s failed for %s: %v", commandPath(i.ApplicationCommandData()), interactionUser(i).Username, failure)
+
+	if err := s.InteractionResponseDelete(i.Interaction); err != nil {
+		log.Printf("Error deleting deferred response: %v", err)
+	}
+	_, err := s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
+		Content: fmt.Sprintf("❌ %v", failure),
+		Flags:   discordgo.MessageFlagsEphemeral,
+	})
+	if err != nil {
+		log.Printf("Error sending followup: %v", err)
+	}
+}
+
+// interactionUser returns the user who triggered an interaction, whether it
+// happened in a guild or a DM.
+func interactionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// respondEphemeral replies to an interaction with a message only the invoking
+// user can see.
+func respondEphemeral(s Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+func handleProjectCommand(s Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	sub := i.ApplicationCommandData().Options[0]
+
+	switch sub.Name {
+	case "switch":
+		name, err := normalizeName("project", sub.Options[0].StringValue())
+		if err != nil {
+			respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+			return
+		}
+
+		dbMu.Lock()
+		activity, err := getOrCreateActivity(user.ID, user.Username)
+		if err != nil {
+			dbMu.Unlock()
+			respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+			return
+		}
+		created := activity.switchProject(name, time.Now())
+		storeActivity(activity)
+		dbMu.Unlock()
+		requestSave()
+
+		if created {
+			respondEphemeral(s, i, fmt.Sprintf("🆕 Created project **%s**. Your check-ins now count towards it.", name))
+		} else {
+			respondEphemeral(s, i, fmt.Sprintf("🔀 Switched to **%s**. Your check-ins now count towards it.", name))
+		}
+		log.Printf("%s switched to project %s", user.Username, name)
+
+	case "list":
+		dbMu.Lock()
+		activity, _, err := activityFor(user.ID)
+		if err != nil {
+			dbMu.Unlock()
+			respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+			return
+		}
+		active := activity.activeProjectName()
+		names := make([]string, 0, len(activity.Projects))
+		for name := range activity.Projects {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var lines []string
+		for _, name := range names {
+			marker := "•"
+			if name == active {
+				marker = "▶"
+			}
+			project := activity.Projects[name]
+			lines = append(lines, fmt.Sprintf("%s **%s**: %d recent check-ins", marker, name, len(project.CheckIns)))
+
+			metricNames := make([]string, 0, len(project.Metrics))
+			for metricName := range project.Metrics {
+				metricNames = append(metricNames, metricName)
+			}
+			sort.Strings(metricNames)
+			for _, metricName := range metricNames {
+				lines = append(lines, "  ↳ "+project.Metrics[metricName].summary(time.Now()))
+			}
+		}
+		dbMu.Unlock()
+
+		if len(lines) == 0 {
+			respondEphemeral(s, i, fmt.Sprintf("You don't have any projects yet. Check-ins go to **%s** until you run `/project switch`.", defaultProjectName))
+			return
+		}
+		respondEphemeral(s, i, "📂 Your projects:\n"+strings.Join(lines, "\n"))
+
+	case "delete":
+		name, err := normalizeName("project", sub.Options[0].StringValue())
+		if err != nil {
+			respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+			return
+		}
+
+		dbMu.Lock()
+		activity, _, err := activityFor(user.ID)
+		var id int
+		if err == nil {
+			id, err = activity.trashProject(name, time.Now())
+		}
+		if err == nil {
+			storeActivity(activity)
+		}
+		dbMu.Unlock()
+		if err != nil {
+			respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+			return
+		}
+		requestSave()
+
+		respondEphemeral(s, i, fmt.Sprintf("🗑️ Moved **%s** to the trash. Restore it within %d days with `/trash restore %d`.", name, config.TrashPurgeDays, id))
+		log.Printf("%s deleted project %s", user.Username, name)
+
+	case "window":
+		var start, end string
+		for _, option := range sub.Options {
+			switch option.Name {
+			case "start":
+				start = option.StringValue()
+			case "end":
+				end = option.StringValue()
+			}
+		}
+
+		var window *CheckInWindow
+		if start != "" || end != "" {
+			var err error
+			window, err = newCheckInWindow(start, end)
+			if err != nil {
+				respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+				return
+			}
+		}
+
+		dbMu.Lock()
+		activity, err := getOrCreateActivity(user.ID, user.Username)
+		if err != nil {
+			dbMu.Unlock()
+			respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+			return
+		}
+		name := activity.activeProjectName()
+		activity.switchProject(name, time.Now())
+		project := activity.Projects[name]
+		project.Window = window
+		activity.Projects[name] = project
+		storeActivity(activity)
+		dbMu.Unlock()
+		requestSave()
+
+		if window == nil {
+			respondEphemeral(s, i, fmt.Sprintf("🕒 Cleared the check-in window for **%s**. Reminders go out at the usual time.", name))
+		} else {
+			respondEphemeral(s, i, fmt.Sprintf("🕒 **%s** check-ins are expected between %s and %s. Check-ins outside that still count but get a %s, and reminders wait until %s.",
+				name, window.Start, window.End, outsideWindowReaction, window.End))
+		}
+		log.Printf("%s set the check-in window for %s to %+v", user.Username, name, window)
+
+	case "transfer":
+		recipient := sub.Options[0].UserValue(nil)
+		if resolved := i.ApplicationCommandData().Resolved; resolved != nil && resolved.Users[recipient.ID] != nil {
+			recipient = resolved.Users[recipient.ID]
+		}
+		if recipient.Bot || recipient.ID == user.ID {
+			respondEphemeral(s, i, "❌ Pick another (human) member to hand the project to.")
+			return
+		}
+		// They'd never be able to check in on it
+		if !isTrackedUser(recipient) {
+			respondEphemeral(s, i, fmt.Sprintf("❌ I'm not tracking check-ins for <@%s>, so they can't take over a project.", recipient.ID))
+			return
+		}
+
+		dbMu.Lock()
+		name, err := transferProject(user, recipient, time.Now())
+		dbMu.Unlock()
+		if err != nil {
+			respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+			return
+		}
+		requestSave()
+
+		// Public so the new owner gets pinged
+		err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: fmt.Sprintf("🤝 <@%s> handed project **%s** over to <@%s>. Use `/project switch %s` to start checking in on it.",
+					user.ID, name, recipient.ID, name),
+				AllowedMentions: &discordgo.MessageAllowedMentions{Users: []string{recipient.ID}},
+			},
+		})
+		if err != nil {
+			log.Printf("Error responding to interaction: %v", err)
+		}
+		log.Printf("%s transferred project %s to %s", user.Username, name, recipient.Username)
+	}
+}
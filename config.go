@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Configuration structure
+type Config struct {
+	Token            string `json:"token"`
+	StudyChannelID   string `json:"studyChannelID"`
+	DataDir          string `json:"dataDir"`          // One file per user, plus the shared bot state
+	DatabasePath     string `json:"databasePath"`     // Legacy single data file, migrated into DataDir
+	ReminderTime     string `json:"reminderTime"`     // Format: "15:04" (24h)
+	CheckInFrequency int    `json:"checkInFrequency"` // In hours
+	SaveInterval     int    `json:"saveInterval"`     // In seconds, minimum time between database writes
+	TrashPurgeDays   int    `json:"trashPurgeDays"`   // Days deleted projects stay restorable
+
+	TrackedUsers []string `json:"trackedUsers"` // Usernames whose check-ins are recorded
+
+	HallOfFameChannelID string `json:"hallOfFameChannelID"` // Optional, where all-time records are shown
+	WeeklyThreads       bool   `json:"weeklyThreads"`       // Collect each week's check-ins in a thread
+}
+
+// A config setting that can also be given as an environment variable or a
+// command-line flag
+type configOverride struct {
+	Env   string
+	Flag  string
+	Usage string
+	Set   configSetter
+}
+
+// Parses an override's value into its Config field
+type configSetter struct {
+	apply  func(cfg *Config, value string) error
+	isBool bool // The flag can be given bare, like -weekly-threads
+}
+
+var configOverrides = []configOverride{
+	{"ACCOUNTABOT_TOKEN", "token", "Discord bot token", setString(func(c *Config) *string { return &c.Token })},
+	{"ACCOUNTABOT_STUDY_CHANNEL_ID", "study-channel-id", "ID of the channel to track", setString(func(c *Config) *string { return &c.StudyChannelID })},
+	{"ACCOUNTABOT_TRACKED_USERS", "tracked-users", "Comma-separated usernames whose check-ins are recorded", setStringList(func(c *Config) *[]string { return &c.TrackedUsers })},
+	{"ACCOUNTABOT_DATA_DIR", "data-dir", "Directory to save study data in", setString(func(c *Config) *string { return &c.DataDir })},
+	{"ACCOUNTABOT_DATABASE_PATH", "database-path", "Legacy single data file to migrate from", setString(func(c *Config) *string { return &c.DatabasePath })},
+	{"ACCOUNTABOT_REMINDER_TIME", "reminder-time", "Daily reminder time, 24h HH:MM", setString(func(c *Config) *string { return &c.ReminderTime })},
+	{"ACCOUNTABOT_CHECK_IN_FREQUENCY", "check-in-frequency", "Hours between expected check-ins", setInt(func(c *Config) *int { return &c.CheckInFrequency })},
+	{"ACCOUNTABOT_SAVE_INTERVAL", "save-interval", "Minimum seconds between database writes", setInt(func(c *Config) *int { return &c.SaveInterval })},
+	{"ACCOUNTABOT_TRASH_PURGE_DAYS", "trash-purge-days", "Days deleted projects stay restorable", setInt(func(c *Config) *int { return &c.TrashPurgeDays })},
+	{"ACCOUNTABOT_HALL_OF_FAME_CHANNEL_ID", "hall-of-fame-channel-id", "ID of the channel to keep the Hall of Fame in", setString(func(c *Config) *string { return &c.HallOfFameChannelID })},
+	{"ACCOUNTABOT_WEEKLY_THREADS", "weekly-threads", "Collect each week's check-ins in a thread", setBool(func(c *Config) *bool { return &c.WeeklyThreads })},
+}
+
+func setString(field func(*Config) *string) configSetter {
+	return configSetter{apply: func(cfg *Config, value string) error {
+		*field(cfg) = value
+		return nil
+	}}
+}
+
+func setStringList(field func(*Config) *[]string) configSetter {
+	return configSetter{apply: func(cfg *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(cfg) = list
+		return nil
+	}}
+}
+
+func setInt(field func(*Config) *int) configSetter {
+	return configSetter{apply: func(cfg *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		*field(cfg) = n
+		return nil
+	}}
+}
+
+func setBool(field func(*Config) *bool) configSetter {
+	return configSetter{isBool: true, apply: func(cfg *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*field(cfg) = b
+		return nil
+	}}
+}
+
+// configFlag holds the raw value of an override given on the command line.
+// It's only parsed in loadConfig, so flags and environment variables report
+// errors the same way.
+type configFlag struct {
+	value  string
+	isBool bool
+}
+
+func (f *configFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+func (f *configFlag) Set(value string) error {
+	f.value = value
+	return nil
+}
+
+// IsBoolFlag lets bool overrides be given bare, like Go's own bool flags.
+func (f *configFlag) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// registerConfigFlags adds a flag for every config override to fs. The
+// returned function reports the flags that were set after fs is parsed, by
+// flag name.
+func registerConfigFlags(fs *flag.FlagSet) func() map[string]string {
+	for _, override := range configOverrides {
+		value := &configFlag{isBool: override.Set.isBool}
+		fs.Var(value, override.Flag, fmt.Sprintf("%s (overrides config file and $%s)", override.Usage, override.Env))
+	}
+
+	return func() map[string]string {
+		set := make(map[string]string)
+		fs.Visit(func(f *flag.Flag) {
+			set[f.Name] = f.Value.String()
+		})
+		return set
+	}
+}
+
+// loadConfig reads the config file at path, overrides it with environment
+// variables and then with flags (by flag name), applies defaults and returns
+// the result. A missing config file is fine if everything else is set some
+// other way. It does not validate the values; see validateConfig.
+func loadConfig(path string, flags map[string]string) (Config, error) {
+	var cfg Config
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return cfg, fmt.Errorf("reading config file: %w", err)
+	}
+	if err != nil {
+		log.Printf("No config file at %s, using environment variables and flags only", path)
+	} else {
+		err = json.Unmarshal(data, &cfg)
+		if err != nil {
+			return cfg, fmt.Errorf("parsing config file: %w", err)
+		}
+	}
+
+	for _, override := range configOverrides {
+		if value, ok := os.LookupEnv(override.Env); ok {
+			if err := override.Set.apply(&cfg, value); err != nil {
+				return cfg, fmt.Errorf("$%s: %w", override.Env, err)
+			}
+		}
+	}
+	for _, override := range configOverrides {
+		if value, ok := flags[override.Flag]; ok {
+			if err := override.Set.apply(&cfg, value); err != nil {
+				return cfg, fmt.Errorf("-%s: %w", override.Flag, err)
+			}
+		}
+	}
+
+	// Set defaults
+	if len(cfg.TrackedUsers) == 0 {
+		cfg.TrackedUsers = []string{"kevin.you"}
+	}
+	if cfg.DataDir == "" {
+		cfg.DataDir = "study_data"
+	}
+	if cfg.DatabasePath == "" {
+		cfg.DatabasePath = "study_data.json"
+	}
+	if cfg.ReminderTime == "" {
+		cfg.ReminderTime = "09:00"
+	}
+	if cfg.CheckInFrequency == 0 {
+		cfg.CheckInFrequency = 24
+	}
+	if cfg.SaveInterval == 0 {
+		cfg.SaveInterval = 5
+	}
+	if cfg.TrashPurgeDays == 0 {
+		cfg.TrashPurgeDays = 30
+	}
+
+	return cfg, nil
+}
+
+// validateConfig checks the config values that can be verified offline and
+// returns a description of every problem found.
+func validateConfig(cfg Config) []string {
+	var problems []string
+
+	if cfg.Token == "" {
+		problems = append(problems, "Discord token is required (token in config.json, $ACCOUNTABOT_TOKEN or -token)")
+	}
+	if cfg.StudyChannelID == "" {
+		problems = append(problems, "Study channel ID is required (studyChannelID in config.json, $ACCOUNTABOT_STUDY_CHANNEL_ID or -study-channel-id)")
+	}
+	if _, _, err := parseClockTime(cfg.ReminderTime); err != nil {
+		problems = append(problems, fmt.Sprintf("Invalid reminderTime %q: expected 24h HH:MM", cfg.ReminderTime))
+	}
+	if cfg.CheckInFrequency < 0 {
+		problems = append(problems, fmt.Sprintf("Invalid checkInFrequency %d: must be a positive number of hours", cfg.CheckInFrequency))
+	}
+	if cfg.SaveInterval < 0 {
+		problems = append(problems, fmt.Sprintf("Invalid saveInterval %d: must be a positive number of seconds", cfg.SaveInterval))
+	}
+	if cfg.TrashPurgeDays < 0 {
+		problems = append(problems, fmt.Sprintf("Invalid trashPurgeDays %d: must be a positive number of days", cfg.TrashPurgeDays))
+	}
+
+	return problems
+}
+
+// checkDiscordConfig verifies the config against Discord itself: the token
+// must authenticate and the study channel must be visible to the bot, and let
+// it run weekly threads if they're on.
+func checkDiscordConfig(s Session, cfg Config) []string {
+	var problems []string
+
+	user, err := s.User("@me")
+	if err != nil {
+		// Nothing else can be checked without a working token
+		return append(problems, fmt.Sprintf("Discord token was rejected: %v", err))
+	}
+	if !user.Bot {
+		problems = append(problems, fmt.Sprintf("Token belongs to %s, which is not a bot account", user.Username))
+	}
+
+	channel, err := s.Channel(cfg.StudyChannelID)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("Study channel %s could not be resolved: %v", cfg.StudyChannelID, err))
+	} else if channel.Type != discordgo.ChannelTypeGuildText {
+		problems = append(problems, fmt.Sprintf("Study channel %s (#%s) is not a text channel", cfg.StudyChannelID, channel.Name))
+	} else if cfg.WeeklyThreads {
+		permissions, err := s.UserChannelPermissions(user.ID, cfg.StudyChannelID)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Could not check the bot's permissions in the study channel: %v", err))
+		} else if permissions&weeklyThreadPermissions != weeklyThreadPermissions {
+			problems = append(problems, "weeklyThreads needs the Create Public Threads, Manage Threads and Send Messages in Threads permissions in the study channel")
+		}
+	}
+
+	if cfg.HallOfFameChannelID != "" {
+		channel, err := s.Channel(cfg.HallOfFameChannelID)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Hall of Fame channel %s could not be resolved: %v", cfg.HallOfFameChannelID, err))
+		} else if channel.Type != discordgo.ChannelTypeGuildText {
+			problems = append(problems, fmt.Sprintf("Hall of Fame channel %s (#%s) is not a text channel", cfg.HallOfFameChannelID, channel.Name))
+		}
+	}
+
+	return problems
+}
+
+// parseClockTime parses a 24h "HH:MM" time of day.
+func parseClockTime(value string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.Hour(), t.Minute(), nil
+}
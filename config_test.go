@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+)
+
+func TestBoolConfigFlags(t *testing.T) {
+	t.Setenv("ACCOUNTABOT_WEEKLY_THREADS", "false")
+
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{}, false},
+		{[]string{"-weekly-threads"}, true},
+		{[]string{"-weekly-threads=true"}, true},
+		{[]string{"-weekly-threads=false"}, false},
+	}
+
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("accountabot", flag.ContinueOnError)
+		configFlags := registerConfigFlags(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+
+		cfg, err := loadConfig(filepath.Join(t.TempDir(), "config.json"), configFlags())
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if cfg.WeeklyThreads != tt.want {
+			t.Errorf("%v: weeklyThreads is %v, want %v", tt.args, cfg.WeeklyThreads, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// A rate limit allowing Max uses per Window
+type rateLimit struct {
+	Max    int
+	Window time.Duration
+}
+
+// Limit applied to any command without its own entry in commandLimits
+var defaultCommandLimit = rateLimit{Max: 10, Window: time.Minute}
+
+// Per-command limits, keyed by command path ("project switch")
+var commandLimits = map[string]rateLimit{
+	"project switch":   {Max: 5, Window: time.Minute},
+	"project delete":   {Max: 3, Window: time.Minute},
+	"metric define":    {Max: 5, Window: time.Minute},
+	"project transfer": {Max: 3, Window: time.Minute},
+}
+
+// How often the rate limiter forgets keys that haven't been used lately
+const rateLimiterSweepInterval = 10 * time.Minute
+
+// rateLimiter tracks recent uses per key within a sliding window. It is kept
+// in memory only, so limits reset when the bot restarts.
+type rateLimiter struct {
+	mu        sync.Mutex
+	uses      map[string][]time.Time
+	longest   time.Duration // Longest window seen, older uses never matter
+	lastSweep time.Time
+}
+
+var commandLimiter = &rateLimiter{uses: make(map[string][]time.Time)}
+
+// allow records a use of key if it fits within limit. If it doesn't, it
+// returns false and how long until the next use would be allowed.
+func (l *rateLimiter) allow(key string, limit rateLimit, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.longest = max(l.longest, limit.Window)
+	l.sweep(now)
+
+	// Drop uses that have fallen out of the window
+	recent := l.uses[key][:0]
+	for _, t := range l.uses[key] {
+		if now.Sub(t) < limit.Window {
+			recent = append(recent, t)
+		}
+	}
+
+	if len(recent) >= limit.Max {
+		l.uses[key] = recent
+		return false, recent[0].Add(limit.Window).Sub(now)
+	}
+
+	l.uses[key] = append(recent, now)
+	return true, 0
+}
+
+// sweep forgets keys whose last use is outside every window, so members who
+// stop using commands don't stay in memory. It runs at most once per
+// rateLimiterSweepInterval. The caller must hold l.mu.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, uses := range l.uses {
+		if len(uses) == 0 || now.Sub(uses[len(uses)-1]) >= l.longest {
+			delete(l.uses, key)
+		}
+	}
+}
+
+// commandPath returns the command name followed by its subcommand, if any,
+// e.g. "project switch".
+func commandPath(data discordgo.ApplicationCommandInteractionData) string {
+	if len(data.Options) > 0 && data.Options[0].Type == discordgo.ApplicationCommandOptionSubCommand {
+		return data.Name + " " + data.Options[0].Name
+	}
+	return data.Name
+}
+
+// limitFor returns the rate limit that applies to a command path.
+func limitFor(path string) rateLimit {
+	if limit, ok := commandLimits[path]; ok {
+		return limit
+	}
+	return defaultCommandLimit
+}
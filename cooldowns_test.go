@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterForgetsIdleKeys(t *testing.T) {
+	limiter := &rateLimiter{uses: make(map[string][]time.Time)}
+	limit := rateLimit{Max: 2, Window: time.Minute}
+	start := time.Now()
+
+	limiter.allow("idle:checkin", limit, start)
+	limiter.allow("busy:checkin", limit, start)
+
+	// Past the window and the sweep interval, only the key in use remains
+	now := start.Add(rateLimiterSweepInterval)
+	allowed, _ := limiter.allow("busy:checkin", limit, now)
+	if !allowed {
+		t.Fatal("use after the window was refused")
+	}
+	if _, ok := limiter.uses["idle:checkin"]; ok {
+		t.Error("idle key wasn't forgotten")
+	}
+	if uses := limiter.uses["busy:checkin"]; len(uses) != 1 {
+		t.Errorf("busy key has %d uses, want 1", len(uses))
+	}
+}
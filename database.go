@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of check-ins kept per user and per project
+const maxCheckIns = 30
+
+// User activity tracking
+type UserActivity struct {
+	UserID      string      `json:"userID"`
+	Username    string      `json:"username"`
+	LastCheckIn time.Time   `json:"lastCheckIn"`
+	CheckIns    []time.Time `json:"checkIns"`
+
+	ActiveProject string             `json:"activeProject,omitempty"`
+	Projects      map[string]Project `json:"projects,omitempty"` // project name -> project
+
+	ReengagementSentAt time.Time `json:"reengagementSentAt,omitzero"` // Set while a re-engagement DM is unanswered
+	PausedUntil        time.Time `json:"pausedUntil,omitzero"`
+	LastReminderAt     time.Time `json:"lastReminderAt,omitzero"`
+	IgnoredReminders   int       `json:"ignoredReminders,omitempty"` // Reminders sent since the last check-in
+	AbsenceResetAt     time.Time `json:"absenceResetAt,omitzero"`    // Absence is counted from here if later than LastCheckIn
+
+	CurrentStreak int    `json:"currentStreak,omitempty"` // Consecutive days with a check-in
+	LongestStreak int    `json:"longestStreak,omitempty"`
+	LastStreakDay string `json:"lastStreakDay,omitempty"` // Format: "2006-01-02"
+	Week          string `json:"week,omitempty"`          // ISO week WeekCheckIns counts, e.g. "2024-W03"
+	WeekCheckIns  int    `json:"weekCheckIns,omitempty"`
+	RestDay       string `json:"restDay,omitempty"` // Format: "2006-01-02", doesn't break the streak
+
+	LateNights      int       `json:"lateNights,omitempty"`    // Consecutive nights with a late check-in, see trackLateNight
+	LastLateNight   string    `json:"lastLateNight,omitempty"` // Format: "2006-01-02", the evening the night started on
+	RestNudgeSentAt time.Time `json:"restNudgeSentAt,omitzero"`
+
+	Trash       []TrashedProject `json:"trash,omitempty"`
+	NextTrashID int              `json:"nextTrashID,omitempty"` // Last trash ID handed out
+}
+
+// Database structure. UserActivities only holds the users currently loaded
+// into memory; see activityFor. Reminders covers every user.
+type Database struct {
+	UserActivities map[string]UserActivity  `json:"userActivities"` // userID -> activity
+	HallOfFame     HallOfFame               `json:"hallOfFame"`
+	WeeklyThread   WeeklyThread             `json:"weeklyThread"`
+	Reminders      map[string]ReminderState `json:"reminders,omitempty"` // userID -> reminder index entry
+}
+
+// Everything in the database that isn't kept in user files, stored in
+// botStateFile
+type botState struct {
+	HallOfFame   HallOfFame               `json:"hallOfFame"`
+	WeeklyThread WeeklyThread             `json:"weeklyThread"`
+	Reminders    map[string]ReminderState `json:"reminders,omitempty"`
+}
+
+// Layout of config.DataDir: one file per user in usersDir, named after their
+// user ID, and the shared state next to it
+const (
+	usersDir     = "users"
+	botStateFile = "bot.json"
+)
+
+// Loaded users nobody has touched for this long are dropped from memory once
+// their changes are saved, and read back from disk when they're needed again
+const userIdleTimeout = 1 * time.Hour
+
+var (
+	database Database
+
+	// dbMu guards database and the user bookkeeping below. Handlers only
+	// mutate the in-memory copy and call requestSave; the files themselves
+	// are written by databaseWriter.
+	dbMu sync.RWMutex
+
+	knownUsers = make(map[string]bool)      // Every user with data, loaded or not
+	dirtyUsers = make(map[string]bool)      // Loaded users changed since they were last written
+	lastUsed   = make(map[string]time.Time) // When each loaded user was last accessed
+
+	// bot.json as last read or written, so it's only rewritten when it
+	// changes. Only used by loadDatabase and the writer.
+	savedBotState []byte
+
+	// saveRequests holds at most one pending save, so any number of changes
+	// made while a save is pending are written together.
+	saveRequests = make(chan struct{}, 1)
+)
+
+func userFilePath(userID string) string {
+	return filepath.Join(config.DataDir, usersDir, userID+".json")
+}
+
+func botStatePath() string {
+	return filepath.Join(config.DataDir, botStateFile)
+}
+
+// Returned by activityFor when a user's file exists but couldn't be read.
+// Worded so handlers can show it to the user.
+var errActivityUnavailable = errors.New("couldn't load your data right now, please try again later")
+
+// activityFor returns the user's activity, reading it from disk if it isn't
+// loaded yet, and whether the user has any. If their file exists but can't be
+// read it returns errActivityUnavailable, and nothing may be stored for the
+// user, or the fresh record would replace their real one on the next save.
+// The caller must hold dbMu for writing.
+func activityFor(userID string) (UserActivity, bool, error) {
+	activity, loaded := database.UserActivities[userID]
+	if !loaded {
+		if !knownUsers[userID] {
+			return UserActivity{}, false, nil
+		}
+
+		var err error
+		activity, err = readActivity(userID)
+		if err != nil {
+			log.Printf("Error loading data for user %s: %v", userID, err)
+			if !knownUsers[userID] {
+				// Their file is gone or was moved aside, so they start over
+				return UserActivity{}, false, nil
+			}
+			return UserActivity{}, false, errActivityUnavailable
+		}
+
+		// Trash may have expired while they weren't loaded
+		if n := activity.purgeExpired(time.Now()); n > 0 {
+			dirtyUsers[userID] = true
+			requestSave()
+			log.Printf("Purged %d project(s) from %s's trash", n, activity.Username)
+		}
+		database.UserActivities[userID] = activity
+	}
+
+	lastUsed[userID] = time.Now()
+	return activity, true, nil
+}
+
+// readActivity reads a user's file. A file that can't be parsed is moved
+// aside so it isn't overwritten, and the user is forgotten so they start
+// over. So is a user whose file has disappeared.
+func readActivity(userID string) (UserActivity, error) {
+	var activity UserActivity
+
+	path := userFilePath(userID)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			delete(knownUsers, userID)
+			delete(database.Reminders, userID)
+		}
+		return activity, err
+	}
+
+	err = json.Unmarshal(data, &activity)
+	if err != nil {
+		if renameErr := os.Rename(path, path+".corrupt"); renameErr != nil {
+			log.Printf("Error moving aside corrupt data for user %s: %v", userID, renameErr)
+		} else {
+			delete(knownUsers, userID)
+			delete(database.Reminders, userID)
+		}
+		return activity, err
+	}
+	return activity, nil
+}
+
+// storeActivity replaces the user's activity in memory and marks it to be
+// written on the next save. The caller must hold dbMu for writing, and call
+// requestSave once it's released.
+func storeActivity(activity UserActivity) {
+	userID := activity.UserID
+	database.UserActivities[userID] = activity
+	knownUsers[userID] = true
+	dirtyUsers[userID] = true
+	lastUsed[userID] = time.Now()
+	database.Reminders[userID] = activity.reminderState()
+}
+
+// requestSave schedules changed data to be written to disk. It never blocks.
+func requestSave() {
+	select {
+	case saveRequests <- struct{}{}:
+	default:
+		// A save is already pending and will include this change
+	}
+}
+
+// databaseWriter writes changed data whenever a save is requested, at most
+// once per interval, and periodically unloads idle users. When stop is
+// closed it flushes any pending save and closes done.
+func databaseWriter(interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	evict := time.NewTicker(userIdleTimeout)
+	defer evict.Stop()
+
+	for {
+		select {
+		case <-saveRequests:
+			saveDatabase()
+		case now := <-evict.C:
+			evictIdleUsers(now)
+			continue
+		case <-stop:
+			flushPendingSave()
+			return
+		}
+
+		// Let further changes pile up before the next write
+		select {
+		case <-time.After(interval):
+		case <-stop:
+			flushPendingSave()
+			return
+		}
+	}
+}
+
+func flushPendingSave() {
+	select {
+	case <-saveRequests:
+		saveDatabase()
+	default:
+	}
+}
+
+// saveDatabase writes the file of every user changed since the last save,
+// and bot.json if it changed.
+func saveDatabase() {
+	// Only hold the lock while serializing, not during disk I/O
+	dbMu.Lock()
+	users := make(map[string][]byte, len(dirtyUsers))
+	for userID := range dirtyUsers {
+		data, err := json.MarshalIndent(database.UserActivities[userID], "", "  ")
+		if err != nil {
+			log.Printf("Error marshaling data for user %s: %v", userID, err)
+			continue
+		}
+		users[userID] = data
+		delete(dirtyUsers, userID)
+	}
+	state, err := json.MarshalIndent(botState{
+		HallOfFame:   database.HallOfFame,
+		WeeklyThread: database.WeeklyThread,
+		Reminders:    database.Reminders,
+	}, "", "  ")
+	dbMu.Unlock()
+
+	var failed []string
+	for userID, data := range users {
+		err := writeFileAtomic(userFilePath(userID), data)
+		if err != nil {
+			log.Printf("Error writing data for user %s: %v", userID, err)
+			failed = append(failed, userID)
+		}
+	}
+
+	// Keep failed users dirty, which also keeps them loaded, and try again
+	// on the next save
+	if len(failed) > 0 {
+		dbMu.Lock()
+		for _, userID := range failed {
+			dirtyUsers[userID] = true
+		}
+		dbMu.Unlock()
+		requestSave()
+	}
+
+	if err != nil {
+		log.Printf("Error marshaling bot state: %v", err)
+		return
+	}
+	if bytes.Equal(state, savedBotState) {
+		return
+	}
+	err = writeFileAtomic(botStatePath(), state)
+	if err != nil {
+		log.Printf("Error writing bot state file: %v", err)
+		requestSave()
+		return
+	}
+	savedBotState = state
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so a crash mid-write leaves the old file rather than a
+// truncated one.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// evictIdleUsers unloads users that haven't been accessed since
+// userIdleTimeout before now. Users with unsaved changes stay loaded.
+func evictIdleUsers(now time.Time) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	for userID := range database.UserActivities {
+		if !dirtyUsers[userID] && now.Sub(lastUsed[userID]) > userIdleTimeout {
+			delete(database.UserActivities, userID)
+			delete(lastUsed, userID)
+		}
+	}
+}
+
+// loadDatabase reads the shared bot state and finds out which users have
+// data. Users themselves are only read once they're needed.
+func loadDatabase() {
+	err := os.MkdirAll(filepath.Join(config.DataDir, usersDir), 0755)
+	if err != nil {
+		log.Fatalf("Error creating data directory: %v", err)
+	}
+	migrateLegacyDatabase()
+
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	data, err := os.ReadFile(botStatePath())
+	switch {
+	case os.IsNotExist(err):
+		log.Println("No existing database found. Starting fresh.")
+	case err != nil:
+		log.Printf("Error reading bot state file: %v", err)
+	default:
+		var state botState
+		err = json.Unmarshal(data, &state)
+		if err != nil {
+			log.Printf("Error unmarshaling bot state: %v", err)
+			break
+		}
+		database.HallOfFame = state.HallOfFame
+		database.WeeklyThread = state.WeeklyThread
+		database.Reminders = state.Reminders
+		savedBotState = data
+	}
+	if database.Reminders == nil {
+		database.Reminders = make(map[string]ReminderState)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(config.DataDir, usersDir))
+	if err != nil {
+		log.Printf("Error listing user files: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		userID, ok := strings.CutSuffix(entry.Name(), ".json")
+		if ok && !entry.IsDir() {
+			knownUsers[userID] = true
+		}
+	}
+
+	// Index users the reminder index doesn't cover yet, e.g. everyone on the
+	// first start after upgrading. This reads each of their files once.
+	indexed := 0
+	for userID := range knownUsers {
+		if _, ok := database.Reminders[userID]; ok {
+			continue
+		}
+		activity, err := readActivity(userID)
+		if err != nil {
+			log.Printf("Error indexing user %s for reminders: %v", userID, err)
+			continue
+		}
+		if !isTrackedUsername(activity.Username) {
+			continue
+		}
+		database.Reminders[userID] = activity.reminderState()
+		indexed++
+	}
+
+	// Only tracked users get reminders. Commands used to be open to anyone,
+	// which left other members in the index.
+	for userID, state := range database.Reminders {
+		if !knownUsers[userID] || !isTrackedUsername(state.Username) {
+			delete(database.Reminders, userID)
+			requestSave()
+		}
+	}
+	if indexed > 0 {
+		requestSave()
+		log.Printf("Added %d users to the reminder index", indexed)
+	}
+
+	log.Printf("Found data for %d users in %s", len(knownUsers), config.DataDir)
+}
+
+// migrateLegacyDatabase splits the single file everything used to be kept in
+// (config.DatabasePath) into the data directory, unless that already has
+// data. The old file is renamed rather than deleted.
+func migrateLegacyDatabase() {
+	data, err := os.ReadFile(config.DatabasePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading legacy database file: %v", err)
+		}
+		return
+	}
+	if _, err := os.Stat(botStatePath()); err == nil {
+		log.Printf("Ignoring legacy database %s, %s already has data", config.DatabasePath, config.DataDir)
+		return
+	}
+
+	// Don't start fresh on top of data that couldn't be carried over
+	var legacy Database
+	err = json.Unmarshal(data, &legacy)
+	if err != nil {
+		log.Fatalf("Error unmarshaling legacy database %s: %v", config.DatabasePath, err)
+	}
+
+	for userID, activity := range legacy.UserActivities {
+		data, err := json.MarshalIndent(activity, "", "  ")
+		if err == nil {
+			err = writeFileAtomic(userFilePath(userID), data)
+		}
+		if err != nil {
+			log.Fatalf("Error migrating data for user %s: %v", userID, err)
+		}
+	}
+
+	// bot.json goes last, since its presence marks the migration as done
+	reminders := make(map[string]ReminderState, len(legacy.UserActivities))
+	for userID, activity := range legacy.UserActivities {
+		reminders[userID] = activity.reminderState()
+	}
+	state, err := json.MarshalIndent(botState{HallOfFame: legacy.HallOfFame, WeeklyThread: legacy.WeeklyThread, Reminders: reminders}, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(botStatePath(), state)
+	}
+	if err != nil {
+		log.Fatalf("Error migrating bot state: %v", err)
+	}
+
+	err = os.Rename(config.DatabasePath, config.DatabasePath+".migrated")
+	if err != nil {
+		log.Printf("Error renaming legacy database file: %v", err)
+	}
+	log.Printf("Migrated %d users from %s to %s", len(legacy.UserActivities), config.DatabasePath, config.DataDir)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// setupTestDatabase points the database at an empty temporary data directory
+// and resets all in-memory state, including event subscribers.
+func setupTestDatabase(tb testing.TB) {
+	tb.Helper()
+
+	config.TrackedUsers = []string{"kevin.you"}
+	config.DataDir = tb.TempDir()
+	config.DatabasePath = filepath.Join(config.DataDir, "study_data.json")
+	config.ReminderTime = "09:00"
+	config.CheckInFrequency = 24
+	config.TrashPurgeDays = 30
+
+	dbMu.Lock()
+	database = Database{UserActivities: make(map[string]UserActivity)}
+	knownUsers = make(map[string]bool)
+	dirtyUsers = make(map[string]bool)
+	lastUsed = make(map[string]time.Time)
+	savedBotState = nil
+	dbMu.Unlock()
+
+	select {
+	case <-saveRequests:
+	default:
+	}
+	events = &eventBus{subscribers: make(map[reflect.Type][]func(context.Context, any))}
+
+	loadDatabase()
+}
+
+// populateTestDatabase stores users with full check-in histories and writes
+// them out, so benchmarks start from a realistically sized database.
+func populateTestDatabase(tb testing.TB, users int) {
+	tb.Helper()
+
+	now := time.Now()
+	dbMu.Lock()
+	for n := range users {
+		activity := UserActivity{UserID: fmt.Sprint(n), Username: fmt.Sprintf("user%d", n)}
+		for day := maxCheckIns; day > 0; day-- {
+			activity.CheckIns = append(activity.CheckIns, now.AddDate(0, 0, -day))
+		}
+		activity.LastCheckIn = activity.CheckIns[len(activity.CheckIns)-1]
+		activity.switchProject("thesis", now)
+		storeActivity(activity)
+	}
+	dbMu.Unlock()
+	saveDatabase()
+}
+
+// Users in the benchmark database
+const benchmarkUsers = 500
+
+// legacyCheckIn records a check-in the way the bot did before saves were
+// coalesced: the whole database is serialized and written to a single file
+// on every check-in, with the write lock held throughout.
+func legacyCheckIn(userID string) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	activity := database.UserActivities[userID]
+	activity.LastCheckIn = time.Now()
+	activity.CheckIns = append(activity.CheckIns[1:], activity.LastCheckIn)
+	database.UserActivities[userID] = activity
+
+	data, err := json.MarshalIndent(database, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	err = os.WriteFile(config.DatabasePath, data, 0644)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func BenchmarkCheckInSynchronousSave(b *testing.B) {
+	setupTestDatabase(b)
+	populateTestDatabase(b, benchmarkUsers)
+
+	n := 0
+	for b.Loop() {
+		legacyCheckIn(fmt.Sprint(n % benchmarkUsers))
+		n++
+	}
+}
+
+func BenchmarkCheckInCoalescedSave(b *testing.B) {
+	setupTestDatabase(b)
+	populateTestDatabase(b, benchmarkUsers)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go databaseWriter(5*time.Second, stop, done)
+
+	n := 0
+	for b.Loop() {
+		userID := fmt.Sprint(n % benchmarkUsers)
+		_, err := recordCheckIn(context.Background(), CheckInRecorded{UserID: userID, Username: "user" + userID, Source: checkInSourceCommand})
+		if err != nil {
+			b.Fatal(err)
+		}
+		n++
+	}
+
+	b.StopTimer()
+	close(stop)
+	<-done
+}
+
+func TestDatabaseWriterFlushesOnStop(t *testing.T) {
+	setupTestDatabase(t)
+
+	// Long enough that only the flush on stop can write the second change
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go databaseWriter(time.Hour, stop, done)
+
+	for _, userID := range []string{"1", "2"} {
+		_, err := recordCheckIn(context.Background(), CheckInRecorded{UserID: userID, Username: "user" + userID, Source: checkInSourceCommand})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writer didn't stop")
+	}
+
+	for _, userID := range []string{"1", "2"} {
+		activity, err := readActivity(userID)
+		if err != nil {
+			t.Fatalf("user %s wasn't saved: %v", userID, err)
+		}
+		if len(activity.CheckIns) != 1 {
+			t.Errorf("user %s has %d check-ins saved, want 1", userID, len(activity.CheckIns))
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Where a check-in came from
+const (
+	checkInSourceMessage  = "message"
+	checkInSourceReaction = "reaction"
+	checkInSourceThread   = "thread"
+	checkInSourceCommand  = "command"
+)
+
+// Published after a check-in has been stored
+type CheckInRecorded struct {
+	UserID    string
+	Username  string
+	Project   string
+	Source    string // One of the checkInSource constants
+	ChannelID string // Channel and message the check-in came from, if any
+	MessageID string
+	At        time.Time
+
+	OutsideWindow bool
+	Streak        int // Daily streak including this check-in
+	WeekCheckIns  int // Check-ins so far this week, including this one
+	LateNights    int // Late nights in a row including this one, 0 if it wasn't late
+}
+
+// Published after a reminder or re-engagement DM was delivered
+type ReminderSent struct {
+	UserID       string
+	Username     string
+	HoursOverdue int
+	Reengagement bool
+}
+
+// eventBus delivers published events to every subscriber of the event's
+// type, synchronously and in subscription order. Subscribers run on the
+// publisher's goroutine, so they must not be called with dbMu held. They get
+// the publisher's context and pass it on to their Discord calls, so a
+// command that times out stops their work too.
+type eventBus struct {
+	mu          sync.RWMutex
+	subscribers map[reflect.Type][]func(context.Context, any)
+}
+
+var events = &eventBus{subscribers: make(map[reflect.Type][]func(context.Context, any))}
+
+// subscribe registers handler for every published event of type E.
+func subscribe[E any](bus *eventBus, handler func(context.Context, E)) {
+	eventType := reflect.TypeFor[E]()
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.subscribers[eventType] = append(bus.subscribers[eventType], func(ctx context.Context, event any) {
+		handler(ctx, event.(E))
+	})
+}
+
+// publish hands event to the subscribers of its type.
+func publish[E any](ctx context.Context, bus *eventBus, event E) {
+	bus.mu.RLock()
+	handlers := bus.subscribers[reflect.TypeFor[E]()]
+	bus.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ctx, event)
+	}
+}
+
+// Audit log of everything that happens
+func logCheckIn(e CheckInRecorded) {
+	log.Printf("Check-in recorded for %s (%s) on %s from a %s", e.Username, e.UserID, e.Project, e.Source)
+}
+
+func logReminder(e ReminderSent) {
+	if e.Reengagement {
+		log.Printf("Sent re-engagement DM to %s", e.Username)
+		return
+	}
+	log.Printf("Sent reminder to %s (%d hours overdue)", e.Username, e.HoursOverdue)
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const testChannelID = "study"
+
+var (
+	trackedUser   = &discordgo.User{ID: "100", Username: "kevin.you"}
+	untrackedUser = &discordgo.User{ID: "200", Username: "someone"}
+)
+
+// newTestBot sets up an empty database and a fake Discord, wired up the way
+// main does it.
+func newTestBot(t *testing.T) *fakeSession {
+	t.Helper()
+
+	setupTestDatabase(t)
+	config.StudyChannelID = testChannelID
+	config.HallOfFameChannelID = ""
+	config.WeeklyThreads = false
+	activeCheckInMode = checkInModeMessages
+	commandLimiter = &rateLimiter{uses: make(map[string][]time.Time)}
+
+	s := newFakeSession()
+	subscribe(events, func(ctx context.Context, e CheckInRecorded) { acknowledgeCheckIn(ctx, s, e) })
+	subscribe(events, func(ctx context.Context, e CheckInRecorded) { checkRecords(ctx, s, e) })
+	subscribe(events, func(ctx context.Context, e CheckInRecorded) { suggestRest(ctx, s, e) })
+	return s
+}
+
+func message(author *discordgo.User, channelID, id string) *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        id,
+		ChannelID: channelID,
+		Author:    author,
+		Content:   "Finished chapter 3",
+	}}
+}
+
+func command(user *discordgo.User, name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:      discordgo.InteractionApplicationCommand,
+		ChannelID: testChannelID,
+		Member:    &discordgo.Member{User: user},
+		Data:      discordgo.ApplicationCommandInteractionData{Name: name, Options: options},
+	}}
+}
+
+func stringOption(name, value string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionString, Value: value}
+}
+
+func subcommand(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionSubCommand, Options: options}
+}
+
+func buttonPress(user *discordgo.User, customID string) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type: discordgo.InteractionMessageComponent,
+		User: user, // Buttons are pressed in DMs
+		Data: discordgo.MessageComponentInteractionData{CustomID: customID},
+	}}
+}
+
+// storedActivity returns what the database holds for userID.
+func storedActivity(t *testing.T, userID string) (UserActivity, bool) {
+	t.Helper()
+
+	dbMu.Lock()
+	defer dbMu.Unlock()
+	activity, exists, err := activityFor(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return activity, exists
+}
+
+func TestMessageCheckIn(t *testing.T) {
+	bot := &discordgo.User{ID: "1", Username: "kevin.you", Bot: true}
+
+	tests := []struct {
+		name        string
+		author      *discordgo.User
+		channelID   string
+		mode        checkInMode
+		wantCheckIn bool
+	}{
+		{"tracked user in study channel", trackedUser, testChannelID, checkInModeMessages, true},
+		{"tracked user elsewhere", trackedUser, "general", checkInModeMessages, false},
+		{"untracked user", untrackedUser, testChannelID, checkInModeMessages, false},
+		{"bot", bot, testChannelID, checkInModeMessages, false},
+		{"content-free mode", trackedUser, testChannelID, checkInModeContentFree, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestBot(t)
+			activeCheckInMode = tt.mode
+
+			messageCreate(s, message(tt.author, tt.channelID, "m1"))
+
+			activity, exists := storedActivity(t, tt.author.ID)
+			reactions := s.callsTo("MessageReactionAdd")
+			if !tt.wantCheckIn {
+				if exists || len(reactions) > 0 {
+					t.Fatalf("recorded a check-in: %+v, reactions %+v", activity, reactions)
+				}
+				return
+			}
+
+			if len(activity.CheckIns) != 1 || activity.LastCheckIn.IsZero() {
+				t.Errorf("stored %d check-ins, last at %v; want 1", len(activity.CheckIns), activity.LastCheckIn)
+			}
+			if activity.CurrentStreak != 1 || activity.WeekCheckIns != 1 {
+				t.Errorf("streak %d, week check-ins %d; want 1 and 1", activity.CurrentStreak, activity.WeekCheckIns)
+			}
+			if project := activity.Projects[defaultProjectName]; len(project.CheckIns) != 1 {
+				t.Errorf("%s has %d check-ins, want 1", defaultProjectName, len(project.CheckIns))
+			}
+			if len(reactions) != 1 || reactions[0].MessageID != "m1" || reactions[0].Content != checkInReaction {
+				t.Errorf("reactions %+v, want %s on m1", reactions, checkInReaction)
+			}
+		})
+	}
+}
+
+func TestReactionCheckIn(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		author      *discordgo.User
+		posted      time.Time
+		wantCheckIn bool
+	}{
+		{"own message from today", trackedUser, now, true},
+		{"own message from yesterday", trackedUser, now.AddDate(0, 0, -1), false},
+		{"someone else's message", untrackedUser, now, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestBot(t)
+			activeCheckInMode = checkInModeContentFree
+			s.messages["m1"] = &discordgo.Message{ID: "m1", ChannelID: testChannelID, Author: tt.author, Timestamp: tt.posted}
+
+			messageReactionAdd(s, &discordgo.MessageReactionAdd{
+				MessageReaction: &discordgo.MessageReaction{
+					UserID:    trackedUser.ID,
+					MessageID: "m1",
+					ChannelID: testChannelID,
+					Emoji:     discordgo.Emoji{Name: checkInReaction},
+				},
+				Member: &discordgo.Member{User: trackedUser},
+			})
+
+			activity, _ := storedActivity(t, trackedUser.ID)
+			if got := len(activity.CheckIns) == 1; got != tt.wantCheckIn {
+				t.Errorf("stored %d check-ins, want a check-in: %v", len(activity.CheckIns), tt.wantCheckIn)
+			}
+		})
+	}
+}
+
+func TestCheckInCommand(t *testing.T) {
+	s := newTestBot(t)
+
+	interactionCreate(s, command(trackedUser, "checkin", stringOption("update", "read chapter 3")))
+
+	responses := s.callsTo("InteractionRespond")
+	if len(responses) != 1 || responses[0].Data.(*discordgo.InteractionResponse).Type != discordgo.InteractionResponseDeferredChannelMessageWithSource {
+		t.Fatalf("responses %+v, want a deferred response", responses)
+	}
+	edit := s.waitFor(t, "InteractionResponseEdit")
+	if want := "✅ <@100> checked in: read chapter 3"; edit.Content != want {
+		t.Errorf("response %q, want %q", edit.Content, want)
+	}
+
+	activity, _ := storedActivity(t, trackedUser.ID)
+	if len(activity.CheckIns) != 1 {
+		t.Errorf("stored %d check-ins, want 1", len(activity.CheckIns))
+	}
+}
+
+func TestCheckInAfterTimeout(t *testing.T) {
+	s := newTestBot(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := recordCheckIn(ctx, CheckInRecorded{
+		UserID:    trackedUser.ID,
+		Username:  trackedUser.Username,
+		Source:    checkInSourceCommand,
+		ChannelID: testChannelID,
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if _, exists := storedActivity(t, trackedUser.ID); exists {
+		t.Error("stored a check-in after the command timed out")
+	}
+	if len(s.calls) > 0 {
+		t.Errorf("called Discord after the command timed out: %+v", s.calls)
+	}
+}
+
+func TestCheckInCommandUntracked(t *testing.T) {
+	s := newTestBot(t)
+
+	interactionCreate(s, command(untrackedUser, "checkin"))
+
+	// Refused privately, without a public "thinking..." placeholder first
+	responses := s.callsTo("InteractionRespond")
+	if len(responses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(responses))
+	}
+	response := responses[0].Data.(*discordgo.InteractionResponse)
+	if response.Type != discordgo.InteractionResponseChannelMessageWithSource || response.Data.Flags != discordgo.MessageFlagsEphemeral {
+		t.Errorf("response %+v, want an ephemeral message", response)
+	}
+	if _, exists := storedActivity(t, untrackedUser.ID); exists {
+		t.Error("stored activity for an untracked user")
+	}
+}
+
+func TestProjectSwitchThenCheckIn(t *testing.T) {
+	s := newTestBot(t)
+
+	interactionCreate(s, command(trackedUser, "project", subcommand("switch", stringOption("name", "Thesis"))))
+	if reply := s.waitFor(t, "InteractionRespond"); !strings.Contains(reply.Content, "Created project **thesis**") {
+		t.Errorf("reply %q doesn't confirm the new project", reply.Content)
+	}
+
+	messageCreate(s, message(trackedUser, testChannelID, "m1"))
+
+	activity, _ := storedActivity(t, trackedUser.ID)
+	if activity.ActiveProject != "thesis" {
+		t.Errorf("active project %q, want thesis", activity.ActiveProject)
+	}
+	if n := len(activity.Projects["thesis"].CheckIns); n != 1 {
+		t.Errorf("thesis has %d check-ins, want 1", n)
+	}
+	if n := len(activity.Projects[defaultProjectName].CheckIns); n != 0 {
+		t.Errorf("%s has %d check-ins, want 0", defaultProjectName, n)
+	}
+}
+
+func TestReminderDispatch(t *testing.T) {
+	s := newTestBot(t)
+	now := time.Now()
+
+	// The reminder slot is this minute
+	config.ReminderTime = now.Format("15:04")
+
+	dbMu.Lock()
+	storeActivity(UserActivity{UserID: "1", Username: "overdue", LastCheckIn: now.Add(-30 * time.Hour)})
+	storeActivity(UserActivity{UserID: "2", Username: "absent", LastCheckIn: now.AddDate(0, 0, -20)})
+	storeActivity(UserActivity{UserID: "3", Username: "recent", LastCheckIn: now.Add(-2 * time.Hour)})
+	dbMu.Unlock()
+
+	// Set up a project but never checked in
+	interactionCreate(s, command(trackedUser, "project", subcommand("switch", stringOption("name", "thesis"))))
+
+	checkAndSendReminders(s)
+
+	reminders := s.callsTo("ChannelMessageSend")
+	if len(reminders) != 2 {
+		t.Fatalf("reminders %+v, want one for <@1> and one for <@100>", reminders)
+	}
+	for _, reminder := range reminders {
+		if reminder.ChannelID != testChannelID {
+			t.Errorf("reminder %q sent to %s, want the study channel", reminder.Content, reminder.ChannelID)
+		}
+		if strings.Contains(reminder.Content, "<@100>") && !strings.Contains(reminder.Content, "haven't checked in yet") {
+			t.Errorf("reminder %q for a user who never checked in", reminder.Content)
+		}
+	}
+	dms := s.callsTo("ChannelMessageSendComplex")
+	if len(dms) != 1 || dms[0].ChannelID != "dm-2" {
+		t.Fatalf("DMs %+v, want one re-engagement DM to user 2", dms)
+	}
+	if buttons := dms[0].Data.(*discordgo.MessageSend).Components; len(buttons) != 1 {
+		t.Errorf("re-engagement DM has components %+v, want one row of buttons", buttons)
+	}
+
+	overdue, _ := storedActivity(t, "1")
+	if overdue.IgnoredReminders != 1 || overdue.LastReminderAt.IsZero() {
+		t.Errorf("overdue user has %d ignored reminders, last at %v; want 1", overdue.IgnoredReminders, overdue.LastReminderAt)
+	}
+	absent, _ := storedActivity(t, "2")
+	if absent.ReengagementSentAt.IsZero() {
+		t.Error("re-engagement DM wasn't recorded")
+	}
+
+	// Only once per slot
+	checkAndSendReminders(s)
+	if n := len(s.callsTo("ChannelMessageSend")) + len(s.callsTo("ChannelMessageSendComplex")); n != 3 {
+		t.Errorf("%d messages after checking twice, want 3", n)
+	}
+
+	// Resuming clears the absence, and the buttons are replaced
+	interactionCreate(s, buttonPress(&discordgo.User{ID: "2", Username: "absent"}, reengageResumeID))
+	responses := s.callsTo("InteractionRespond")
+	response := responses[len(responses)-1].Data.(*discordgo.InteractionResponse)
+	if response.Type != discordgo.InteractionResponseUpdateMessage || len(response.Data.Components) != 0 {
+		t.Errorf("response %+v, want the DM updated without buttons", response)
+	}
+	absent, _ = storedActivity(t, "2")
+	if !absent.ReengagementSentAt.IsZero() || absent.AbsenceResetAt.IsZero() {
+		t.Errorf("after resuming: re-engagement sent at %v, absence reset at %v", absent.ReengagementSentAt, absent.AbsenceResetAt)
+	}
+}
+
+func TestReminderDispatchFailureIsRetried(t *testing.T) {
+	s := newTestBot(t)
+	now := time.Now()
+	config.ReminderTime = now.Format("15:04")
+
+	dbMu.Lock()
+	storeActivity(UserActivity{UserID: "1", Username: "overdue", LastCheckIn: now.Add(-30 * time.Hour)})
+	dbMu.Unlock()
+
+	s.fail["ChannelMessageSend"] = discordgo.ErrUnauthorized
+	checkAndSendReminders(s)
+	if activity, _ := storedActivity(t, "1"); !activity.LastReminderAt.IsZero() {
+		t.Fatal("a reminder that failed to send was recorded")
+	}
+
+	delete(s.fail, "ChannelMessageSend")
+	checkAndSendReminders(s)
+	if activity, _ := storedActivity(t, "1"); activity.IgnoredReminders != 1 {
+		t.Errorf("%d reminders recorded after the retry, want 1", activity.IgnoredReminders)
+	}
+}
+
+func TestProjectTransfer(t *testing.T) {
+	s := newTestBot(t)
+	teammate := &discordgo.User{ID: "300", Username: "alex"}
+	config.TrackedUsers = []string{trackedUser.Username, teammate.Username}
+
+	interactionCreate(s, command(trackedUser, "project", subcommand("switch", stringOption("name", "thesis"))))
+	messageCreate(s, message(trackedUser, testChannelID, "m1"))
+
+	transfer := command(trackedUser, "project", subcommand("transfer",
+		&discordgo.ApplicationCommandInteractionDataOption{Name: "user", Type: discordgo.ApplicationCommandOptionUser, Value: teammate.ID}))
+	data := transfer.Data.(discordgo.ApplicationCommandInteractionData)
+	data.Resolved = &discordgo.ApplicationCommandInteractionDataResolved{Users: map[string]*discordgo.User{teammate.ID: teammate}}
+	transfer.Data = data
+	interactionCreate(s, transfer)
+
+	responses := s.callsTo("InteractionRespond")
+	if reply := responses[len(responses)-1]; !strings.Contains(reply.Content, "handed project **thesis** over to <@300>") {
+		t.Errorf("reply %q doesn't announce the transfer", reply.Content)
+	}
+
+	sender, _ := storedActivity(t, trackedUser.ID)
+	if _, kept := sender.Projects["thesis"]; kept || sender.ActiveProject != "" {
+		t.Errorf("sender still has thesis, active project %q", sender.ActiveProject)
+	}
+	recipient, _ := storedActivity(t, teammate.ID)
+	project := recipient.Projects["thesis"]
+	if len(project.CheckIns) != 1 || len(project.Transfers) != 1 {
+		t.Errorf("recipient's thesis has %d check-ins and %d transfers, want 1 and 1", len(project.CheckIns), len(project.Transfers))
+	}
+
+	// The new owner can carry on with it
+	interactionCreate(s, command(teammate, "project", subcommand("switch", stringOption("name", "thesis"))))
+	messageCreate(s, message(teammate, testChannelID, "m2"))
+	recipient, _ = storedActivity(t, teammate.ID)
+	if n := len(recipient.Projects["thesis"].CheckIns); n != 2 {
+		t.Errorf("thesis has %d check-ins after the new owner checked in, want 2", n)
+	}
+}
+
+func TestHallOfFameEditFailure(t *testing.T) {
+	unknownMessage := &discordgo.RESTError{Message: &discordgo.APIErrorMessage{Code: discordgo.ErrCodeUnknownMessage}}
+
+	tests := []struct {
+		name       string
+		editErr    error
+		wantRepost bool
+	}{
+		{"edited", nil, false},
+		{"flaky edit", discordgo.ErrStatusOffline, false},
+		{"message deleted", unknownMessage, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestBot(t)
+			config.HallOfFameChannelID = "fame"
+			dbMu.Lock()
+			database.HallOfFame.ChannelID = "fame"
+			database.HallOfFame.MessageID = "hof"
+			dbMu.Unlock()
+			if tt.editErr != nil {
+				s.fail["ChannelMessageEdit"] = tt.editErr
+			}
+
+			refreshHallOfFame(context.Background(), s)
+
+			if n := len(s.callsTo("ChannelMessageEdit")); n != 1 {
+				t.Errorf("%d edits, want 1", n)
+			}
+			reposted := len(s.callsTo("ChannelMessageSendComplex")) > 0
+			if reposted != tt.wantRepost {
+				t.Errorf("reposted: %v, want %v", reposted, tt.wantRepost)
+			}
+			dbMu.RLock()
+			messageID := database.HallOfFame.MessageID
+			dbMu.RUnlock()
+			if kept := messageID == "hof"; kept == tt.wantRepost {
+				t.Errorf("Hall of Fame message is %s after the refresh", messageID)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// An all-time record and who holds it
+type Record struct {
+	UserID   string    `json:"userID"`
+	Username string    `json:"username"`
+	Value    int       `json:"value"`
+	SetAt    time.Time `json:"setAt"`
+}
+
+// All-time records, shown in a message the bot keeps up to date in the
+// configured Hall of Fame channel
+type HallOfFame struct {
+	LongestStreak      Record `json:"longestStreak"`
+	MostCheckInsInWeek Record `json:"mostCheckInsInWeek"`
+
+	ChannelID string `json:"channelID,omitempty"` // Where the message was posted
+	MessageID string `json:"messageID,omitempty"`
+}
+
+// Serializes refreshes so only one message is ever posted
+var hallOfFameMu sync.Mutex
+
+// beat replaces r if value is higher, and reports whether it did.
+func (r *Record) beat(e CheckInRecorded, value int) bool {
+	if value <= r.Value {
+		return false
+	}
+	*r = Record{UserID: e.UserID, Username: e.Username, Value: value, SetAt: e.At}
+	return true
+}
+
+// checkRecords updates the Hall of Fame when a check-in breaks a record.
+func checkRecords(ctx context.Context, s Session, e CheckInRecorded) {
+	if config.HallOfFameChannelID == "" {
+		return
+	}
+
+	dbMu.Lock()
+	streak := database.HallOfFame.LongestStreak.beat(e, e.Streak)
+	week := database.HallOfFame.MostCheckInsInWeek.beat(e, e.WeekCheckIns)
+	dbMu.Unlock()
+
+	if !streak && !week {
+		return
+	}
+	requestSave()
+
+	if streak {
+		log.Printf("%s set a new longest streak: %d days", e.Username, e.Streak)
+	}
+	if week {
+		log.Printf("%s set a new weekly record: %d check-ins", e.Username, e.WeekCheckIns)
+	}
+	refreshHallOfFame(ctx, s)
+}
+
+// refreshHallOfFame edits the Hall of Fame message in place, posting and
+// pinning a new one if it doesn't exist yet or was deleted. Other errors keep
+// the existing message, which is brought up to date on the next refresh.
+func refreshHallOfFame(ctx context.Context, s Session) {
+	if config.HallOfFameChannelID == "" {
+		return
+	}
+
+	hallOfFameMu.Lock()
+	defer hallOfFameMu.Unlock()
+
+	dbMu.RLock()
+	hof := database.HallOfFame
+	dbMu.RUnlock()
+
+	content := formatHallOfFame(hof)
+
+	if hof.MessageID != "" && hof.ChannelID == config.HallOfFameChannelID {
+		_, err := s.ChannelMessageEdit(hof.ChannelID, hof.MessageID, content, discordgo.WithContext(ctx))
+		if err == nil {
+			return
+		}
+		if !isUnknownMessage(err) {
+			log.Printf("Error editing Hall of Fame message: %v", err)
+			return
+		}
+		log.Printf("Hall of Fame message was deleted, posting a new one")
+	}
+
+	message, err := s.ChannelMessageSendComplex(config.HallOfFameChannelID, &discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}, discordgo.WithContext(ctx))
+	if err != nil {
+		log.Printf("Error posting Hall of Fame message: %v", err)
+		return
+	}
+	if err := s.ChannelMessagePin(message.ChannelID, message.ID, discordgo.WithContext(ctx)); err != nil {
+		log.Printf("Error pinning Hall of Fame message: %v", err)
+	}
+
+	dbMu.Lock()
+	database.HallOfFame.ChannelID = message.ChannelID
+	database.HallOfFame.MessageID = message.ID
+	dbMu.Unlock()
+	requestSave()
+}
+
+func formatHallOfFame(hof HallOfFame) string {
+	var b strings.Builder
+	b.WriteString("🏆 **Hall of Fame**\n\n")
+	b.WriteString(formatRecord("🔥 Longest streak", hof.LongestStreak, "days"))
+	b.WriteString(formatRecord("📅 Most check-ins in a week", hof.MostCheckInsInWeek, "check-ins"))
+	return b.String()
+}
+
+func formatRecord(title string, r Record, unit string) string {
+	if r.UserID == "" {
+		return fmt.Sprintf("%s: nobody yet!\n", title)
+	}
+	return fmt.Sprintf("%s: **%d %s** by <@%s> (%s)\n", title, r.Value, unit, r.UserID, r.SetAt.Format("Jan 2, 2006"))
+}
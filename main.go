@@ -1,251 +1,246 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-// Configuration structure
-type Config struct {
-	Token            string `json:"token"`
-	StudyChannelID   string `json:"studyChannelID"`
-	DatabasePath     string `json:"databasePath"`
-	ReminderTime     string `json:"reminderTime"`     // Format: "15:04" (24h)
-	CheckInFrequency int    `json:"checkInFrequency"` // In hours
-}
-
-// User activity tracking
-type UserActivity struct {
-	UserID      string      `json:"userID"`
-	Username    string      `json:"username"`
-	LastCheckIn time.Time   `json:"lastCheckIn"`
-	CheckIns    []time.Time `json:"checkIns"`
-}
-
-// Database structure
-type Database struct {
-	UserActivities map[string]UserActivity `json:"userActivities"` // userID -> activity
-}
-
-var (
-	config   Config
-	database Database
-)
+var config Config
 
 func main() {
-	// Load configuration
-	configFile, err := os.ReadFile("config.json")
+	configPath := flag.String("config", "config.json", "Path to the config file")
+	checkOnly := flag.Bool("check-config", false, "Validate the config against Discord and exit")
+	configFlags := registerConfigFlags(flag.CommandLine)
+	flag.Parse()
+
+	// Load configuration, with environment variables and flags taking precedence
+	var err error
+	config, err = loadConfig(*configPath, configFlags())
 	if err != nil {
-		log.Fatalf("Error reading config file: %v", err)
+		log.Fatalf("Error loading config: %v", err)
 	}
 
-	err = json.Unmarshal(configFile, &config)
-	if err != nil {
-		log.Fatalf("Error parsing config file: %v", err)
+	// Validate config before touching Discord
+	if problems := validateConfig(config); len(problems) > 0 {
+		for _, problem := range problems {
+			log.Printf("Config problem: %s", problem)
+		}
+		log.Fatalf("Found %d problem(s) in the config", len(problems))
 	}
 
-	// Validate required config
-	if config.Token == "" {
-		log.Fatalf("Discord token is required in config.json")
-	}
-	if config.StudyChannelID == "" {
-		log.Fatalf("Study channel ID is required in config.json")
+	// Create Discord session
+	dg, err := discordgo.New("Bot " + config.Token)
+	if err != nil {
+		log.Fatalf("Error creating Discord session: %v", err)
 	}
 
-	// Set defaults
-	if config.DatabasePath == "" {
-		config.DatabasePath = "study_data.json"
+	// Make sure the token and channel are usable before going live
+	if problems := checkDiscordConfig(dg, config); len(problems) > 0 {
+		for _, problem := range problems {
+			log.Printf("Config problem: %s", problem)
+		}
+		log.Fatalf("Found %d problem(s) in the config", len(problems))
 	}
-	if config.ReminderTime == "" {
-		config.ReminderTime = "09:00"
+
+	// Fall back to content-free check-ins without the Message Content intent
+	activeCheckInMode = detectCheckInMode(dg)
+	log.Printf("Check-in mode: %s", activeCheckInMode)
+	if activeCheckInMode == checkInModeMessages {
+		dg.Identify.Intents = discordgo.IntentsAllWithoutPrivileged | discordgo.IntentMessageContent
 	}
-	if config.CheckInFrequency == 0 {
-		config.CheckInFrequency = 24
+
+	if *checkOnly {
+		fmt.Println("✅ Config looks good")
+		return
 	}
 
 	// Initialize database
 	database.UserActivities = make(map[string]UserActivity)
 	loadDatabase()
 
-	// Create Discord session
-	dg, err := discordgo.New("Bot " + config.Token)
-	if err != nil {
-		log.Fatalf("Error creating Discord session: %v", err)
-	}
+	// Subscribe to internal events
+	subscribe(events, func(_ context.Context, e CheckInRecorded) { logCheckIn(e) })
+	subscribe(events, func(_ context.Context, e ReminderSent) { logReminder(e) })
+	subscribe(events, func(ctx context.Context, e CheckInRecorded) { acknowledgeCheckIn(ctx, dg, e) })
+	subscribe(events, func(ctx context.Context, e CheckInRecorded) { checkRecords(ctx, dg, e) })
+	subscribe(events, func(ctx context.Context, e CheckInRecorded) { suggestRest(ctx, dg, e) })
 
 	// Register event handlers
-	dg.AddHandler(messageCreate)
 	dg.AddHandler(ready)
 
+	// These take the Session interface, which discordgo can't dispatch to directly
+	dg.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) { messageCreate(s, m) })
+	dg.AddHandler(func(s *discordgo.Session, r *discordgo.MessageReactionAdd) { messageReactionAdd(s, r) })
+	dg.AddHandler(func(s *discordgo.Session, t *discordgo.ThreadCreate) { threadCreate(s, t) })
+	dg.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) { interactionCreate(s, i) })
+
 	// Open Discord session
 	err = dg.Open()
 	if err != nil {
 		log.Fatalf("Error opening Discord connection: %v", err)
 	}
-	defer dg.Close()
+
+	// Start the database writer
+	stopWriter := make(chan struct{})
+	writerDone := make(chan struct{})
+	go databaseWriter(time.Duration(config.SaveInterval)*time.Second, stopWriter, writerDone)
+
+	// Make sure the Hall of Fame message exists
+	go refreshHallOfFame(context.Background(), dg)
+
+	// Keep this week's thread going
+	if config.WeeklyThreads {
+		go weeklyThreadRoutine(dg)
+	}
 
 	// Start reminder routine
 	go reminderRoutine(dg)
 
+	// Drop expired trash now and then regularly
+	purgeTrash()
+	go trashPurgeRoutine()
+
 	// Wait for a CTRL+C signal
 	fmt.Println("Study accountability bot is now running. Press CTRL+C to exit.")
 	fmt.Printf("Monitoring channel ID: %s\n", config.StudyChannelID)
-	fmt.Println("Tracking user: kevin.you")
+	fmt.Printf("Tracking users: %s\n", strings.Join(config.TrackedUsers, ", "))
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 	<-sc
 
-	// Save database before exiting
-	saveDatabase()
+	// Stop receiving events first, so no check-in lands after the final save
+	err = dg.Close()
+	if err != nil {
+		log.Printf("Error closing Discord connection: %v", err)
+	}
+
+	// Flush any pending save before exiting
+	close(stopWriter)
+	<-writerDone
 	fmt.Println("Bot shutting down...")
 }
 
 func ready(s *discordgo.Session, event *discordgo.Ready) {
 	log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
 
+	registerCommands(s, s.State.User.ID)
+
 	// Set the playing status
-	err := s.UpdateGameStatus(0, "Tracking kevin.you's study progress!")
+	status := "Tracking study progress!"
+	if len(config.TrackedUsers) == 1 {
+		status = fmt.Sprintf("Tracking %s's study progress!", config.TrackedUsers[0])
+	}
+	err := s.UpdateGameStatus(0, status)
 	if err != nil {
 		log.Printf("Error setting status: %v", err)
 	}
 }
 
-func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
-	// Ignore bot's own messages
-	if m.Author.ID == s.State.User.ID {
+func messageCreate(s Session, m *discordgo.MessageCreate) {
+	// Ignore bots, including our own messages
+	if m.Author == nil || m.Author.Bot {
 		return
 	}
 
-	// Only track messages from user "kevin.you" in the studying-updates channel
-	if m.ChannelID == config.StudyChannelID && m.Author.Username == "kevin.you" {
-		// Record this check-in
-		recordCheckIn(m.Author.ID, m.Author.Username)
-
-		// Send a quick acknowledgment (optional)
-		s.MessageReactionAdd(m.ChannelID, m.ID, "✅")
+	// Messages only count as check-ins when the Message Content intent is available
+	if activeCheckInMode != checkInModeMessages {
+		return
+	}
 
-		log.Printf("Check-in recorded for %s (%s)", m.Author.Username, m.Author.ID)
+	// Only track messages from tracked users in the studying-updates channel (or its weekly thread)
+	if isCheckInChannel(m.ChannelID) && isTrackedUser(m.Author) {
+		// Record this check-in
+		_, err := recordCheckIn(context.Background(), CheckInRecorded{
+			UserID:    m.Author.ID,
+			Username:  m.Author.Username,
+			Source:    checkInSourceMessage,
+			ChannelID: m.ChannelID,
+			MessageID: m.ID,
+		})
+		if err != nil {
+			log.Printf("Error recording check-in from %s: %v", m.Author.Username, err)
+		}
 	}
 }
 
-func recordCheckIn(userID, username string) {
-	// Get or create user activity
-	activity, exists := database.UserActivities[userID]
+// getOrCreateActivity returns the stored activity for a user, or a fresh one
+// if they haven't been seen before. It fails, rather than starting the user
+// over, if their data exists but can't be read. The caller must hold dbMu for
+// writing.
+func getOrCreateActivity(userID, username string) (UserActivity, error) {
+	activity, exists, err := activityFor(userID)
+	if err != nil {
+		return activity, err
+	}
 	if !exists {
 		activity = UserActivity{
 			UserID:   userID,
 			Username: username,
 			CheckIns: []time.Time{},
+			// Until their first check-in, count any absence from now
+			AbsenceResetAt: time.Now(),
 		}
 	}
 
 	// Update username in case it changed
 	activity.Username = username
 
-	// Record check-in
-	now := time.Now()
-	activity.LastCheckIn = now
-	activity.CheckIns = append(activity.CheckIns, now)
-
-	// Keep only the last 30 check-ins to prevent unlimited growth
-	if len(activity.CheckIns) > 30 {
-		activity.CheckIns = activity.CheckIns[len(activity.CheckIns)-30:]
-	}
-
-	// Update database
-	database.UserActivities[userID] = activity
-
-	// Save to database
-	saveDatabase()
+	return activity, nil
 }
 
-func saveDatabase() {
-	data, err := json.MarshalIndent(database, "", "  ")
-	if err != nil {
-		log.Printf("Error marshaling database: %v", err)
-		return
-	}
-
-	err = os.WriteFile(config.DatabasePath, data, 0644)
-	if err != nil {
-		log.Printf("Error writing database file: %v", err)
-	}
-}
+// recordCheckIn stores a check-in for the user's active project and publishes
+// it. checkIn only needs the user and where the check-in came from; the rest
+// is filled in and the published event returned. Nothing is stored if ctx is
+// done by the time the user's data is loaded, and subscribers stop their
+// Discord calls once it is.
+func recordCheckIn(ctx context.Context, checkIn CheckInRecorded) (CheckInRecorded, error) {
+	dbMu.Lock()
 
-func loadDatabase() {
-	data, err := os.ReadFile(config.DatabasePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			log.Println("No existing database found. Starting fresh.")
-		} else {
-			log.Printf("Error reading database file: %v", err)
-		}
-		return
+	activity, err := getOrCreateActivity(checkIn.UserID, checkIn.Username)
+	if err == nil {
+		err = ctx.Err()
 	}
-
-	err = json.Unmarshal(data, &database)
 	if err != nil {
-		log.Printf("Error unmarshaling database: %v", err)
-		return
+		dbMu.Unlock()
+		return checkIn, err
 	}
 
-	log.Printf("Loaded %d user activities from database", len(database.UserActivities))
-}
-
-func reminderRoutine(s *discordgo.Session) {
-	// Check every hour
-	ticker := time.NewTicker(1 * time.Hour)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		checkAndSendReminders(s)
-	}
-}
-
-func checkAndSendReminders(s *discordgo.Session) {
+	// Record check-in
 	now := time.Now()
+	activity.LastCheckIn = now
+	activity.CheckIns = append(activity.CheckIns, now)
+	activity.clearAbsence()
+	activity.updateStreak(now)
+	checkIn.LateNights = activity.trackLateNight(now)
 
-	// Parse reminder time (e.g., "09:00")
-	reminderHour := 9
-	reminderMinute := 0
-
-	_, err := fmt.Sscanf(config.ReminderTime, "%d:%d", &reminderHour, &reminderMinute)
-	if err != nil {
-		log.Printf("Error parsing reminder time: %v", err)
-		return
-	}
-
-	// Check if it's the right time to send reminders (within 5 minutes of target time)
-	if now.Hour() != reminderHour || now.Minute() < reminderMinute || now.Minute() > reminderMinute+5 {
-		return
+	// Keep only the last check-ins to prevent unlimited growth
+	if len(activity.CheckIns) > maxCheckIns {
+		activity.CheckIns = activity.CheckIns[len(activity.CheckIns)-maxCheckIns:]
 	}
 
-	// Check all users for overdue check-ins
-	for userID, activity := range database.UserActivities {
-		hoursSinceLastCheckIn := now.Sub(activity.LastCheckIn).Hours()
+	// Attribute it to whichever project is active
+	checkIn.Project = activity.activeProjectName()
+	checkIn.OutsideWindow = activity.recordProjectCheckIn(now)
+	checkIn.At = now
+	checkIn.Streak = activity.CurrentStreak
+	checkIn.WeekCheckIns = activity.WeekCheckIns
 
-		// If user hasn't checked in within the frequency period
-		if hoursSinceLastCheckIn > float64(config.CheckInFrequency) {
-			sendReminder(s, userID, activity.Username, int(hoursSinceLastCheckIn))
-		}
-	}
-}
+	// Update database
+	storeActivity(activity)
+	dbMu.Unlock()
 
-func sendReminder(s *discordgo.Session, userID, username string, hoursSinceLastCheckIn int) {
-	// Send reminder in the study channel
-	message := fmt.Sprintf("📚 Hey <@%s>! It's been %d hours since your last study check-in. How's your progress going today?", userID, hoursSinceLastCheckIn)
+	// Save to database
+	requestSave()
 
-	_, err := s.ChannelMessageSend(config.StudyChannelID, message)
-	if err != nil {
-		log.Printf("Error sending reminder to %s: %v", username, err)
-	} else {
-		log.Printf("Sent reminder to %s (%d hours overdue)", username, hoursSinceLastCheckIn)
-	}
+	publish(ctx, events, checkIn)
+	return checkIn, nil
 }
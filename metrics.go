@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Number of weekly totals kept per metric
+const maxMetricWeeks = 12
+
+// A user-defined number tracked on a project, like words written or
+// kilometers run
+type Metric struct {
+	Name      string             `json:"name"`
+	Unit      string             `json:"unit,omitempty"`
+	CreatedAt time.Time          `json:"createdAt"`
+	Total     float64            `json:"total"`
+	Weekly    map[string]float64 `json:"weekly,omitempty"` // ISO week (see weekKey) -> total logged that week
+}
+
+// log adds amount to the metric's total and to now's week.
+func (m *Metric) log(amount float64, now time.Time) {
+	m.Total += amount
+
+	if m.Weekly == nil {
+		m.Weekly = make(map[string]float64)
+	}
+	m.Weekly[weekKey(now)] += amount
+
+	// Only keep recent weeks; ISO week keys sort chronologically
+	if len(m.Weekly) > maxMetricWeeks {
+		weeks := make([]string, 0, len(m.Weekly))
+		for week := range m.Weekly {
+			weeks = append(weeks, week)
+		}
+		sort.Strings(weeks)
+		for _, week := range weeks[:len(weeks)-maxMetricWeeks] {
+			delete(m.Weekly, week)
+		}
+	}
+}
+
+// formatAmount formats a metric value with its unit, without trailing zeros.
+func (m Metric) formatAmount(amount float64) string {
+	formatted := strconv.FormatFloat(amount, 'f', -1, 64)
+	if m.Unit == "" {
+		return formatted
+	}
+	return formatted + " " + m.Unit
+}
+
+// summary describes the metric's total and this week's progress.
+func (m Metric) summary(now time.Time) string {
+	return fmt.Sprintf("**%s**: %s this week, %s total", m.Name, m.formatAmount(m.Weekly[weekKey(now)]), m.formatAmount(m.Total))
+}
+
+func handleMetricCommand(s Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	sub := i.ApplicationCommandData().Options[0]
+	now := time.Now()
+
+	switch sub.Name {
+	case "define":
+		var name, unit string
+		for _, option := range sub.Options {
+			switch option.Name {
+			case "name":
+				name = option.StringValue()
+			case "unit":
+				unit = strings.TrimSpace(option.StringValue())
+			}
+		}
+		name, err := normalizeName("metric", name)
+		if err != nil {
+			respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+			return
+		}
+
+		dbMu.Lock()
+		activity, err := getOrCreateActivity(user.ID, user.Username)
+		if err != nil {
+			dbMu.Unlock()
+			respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+			return
+		}
+		projectName := activity.activeProjectName()
+		activity.switchProject(projectName, now)
+		project := activity.Projects[projectName]
+		_, exists := project.Metrics[name]
+		if !exists {
+			if project.Metrics == nil {
+				project.Metrics = make(map[string]Metric)
+			}
+			project.Metrics[name] = Metric{Name: name, Unit: unit, CreatedAt: now}
+			activity.Projects[projectName] = project
+			storeActivity(activity)
+		}
+		dbMu.Unlock()
+
+		if exists {
+			respondEphemeral(s, i, fmt.Sprintf("❌ **%s** already tracks a metric called **%s**.", projectName, name))
+			return
+		}
+		requestSave()
+
+		respondEphemeral(s, i, fmt.Sprintf("📏 **%s** now tracks **%s**. Log progress with `/log %s <amount>`.", projectName, name, name))
+		log.Printf("%s defined metric %s on %s", user.Username, name, projectName)
+
+	case "list":
+		dbMu.Lock()
+		activity, _, err := activityFor(user.ID)
+		if err != nil {
+			dbMu.Unlock()
+			respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+			return
+		}
+		projectName := activity.activeProjectName()
+		metrics := activity.Projects[projectName].Metrics
+		names := make([]string, 0, len(metrics))
+		for name := range metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var lines []string
+		for _, name := range names {
+			lines = append(lines, "• "+metrics[name].summary(now))
+		}
+		dbMu.Unlock()
+
+		if len(lines) == 0 {
+			respondEphemeral(s, i, fmt.Sprintf("**%s** doesn't track any metrics yet. Add one with `/metric define`.", projectName))
+			return
+		}
+		respondEphemeral(s, i, fmt.Sprintf("📏 Metrics for **%s**:\n%s", projectName, strings.Join(lines, "\n")))
+	}
+}
+
+func handleLogCommand(s Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	now := time.Now()
+
+	var name string
+	var amount float64
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "metric":
+			name = option.StringValue()
+		case "amount":
+			amount = option.FloatValue()
+		}
+	}
+	name, err := normalizeName("metric", name)
+	if err != nil {
+		respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+		return
+	}
+
+	dbMu.Lock()
+	activity, _, err := activityFor(user.ID)
+	if err != nil {
+		dbMu.Unlock()
+		respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	projectName := activity.activeProjectName()
+	project, projectExists := activity.Projects[projectName]
+	metric, exists := project.Metrics[name]
+	if projectExists && exists {
+		metric.log(amount, now)
+		project.Metrics[name] = metric
+		storeActivity(activity)
+	}
+	dbMu.Unlock()
+
+	if !exists {
+		respondEphemeral(s, i, fmt.Sprintf("❌ **%s** doesn't track **%s**. Define it first with `/metric define %s`.", projectName, name, name))
+		return
+	}
+	requestSave()
+
+	respondEphemeral(s, i, fmt.Sprintf("📈 Logged %s. %s", metric.formatAmount(amount), metric.summary(now)))
+	log.Printf("%s logged %v %s on %s", user.Username, amount, name, projectName)
+}
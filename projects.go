@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Check-ins go to this project until the user switches to another one
+const defaultProjectName = "general"
+
+// Longest project or metric name allowed
+const maxNameLength = 32
+
+// A named project within a user's activity. Every check-in is attributed to
+// the user's active project.
+type Project struct {
+	Name        string      `json:"name"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	LastCheckIn time.Time   `json:"lastCheckIn"`
+	CheckIns    []time.Time `json:"checkIns"`
+	ArchivedAt  time.Time   `json:"archivedAt,omitzero"`
+
+	Window                *CheckInWindow `json:"window,omitempty"`
+	OutsideWindowCheckIns int            `json:"outsideWindowCheckIns,omitempty"`
+
+	Transfers []ProjectTransfer `json:"transfers,omitempty"` // Oldest first
+
+	Metrics map[string]Metric `json:"metrics,omitempty"` // metric name -> metric
+}
+
+// A handoff of a project from one member to another
+type ProjectTransfer struct {
+	FromUserID string    `json:"fromUserID"`
+	ToUserID   string    `json:"toUserID"`
+	At         time.Time `json:"at"`
+}
+
+// normalizeName turns user input into the key a project or metric (kind)
+// is stored under, so "Thesis " and "thesis" refer to the same project.
+func normalizeName(kind, name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", fmt.Errorf("%s name can't be empty", kind)
+	}
+	if len(name) > maxNameLength {
+		return "", fmt.Errorf("%s name can be at most %d characters", kind, maxNameLength)
+	}
+	return name, nil
+}
+
+// activeProjectName returns the name of the project check-ins are currently
+// attributed to.
+func (a *UserActivity) activeProjectName() string {
+	if a.ActiveProject == "" {
+		return defaultProjectName
+	}
+	return a.ActiveProject
+}
+
+// switchProject makes name the active project, creating it if needed or
+// bringing it back from the archive. It reports whether the project is new.
+func (a *UserActivity) switchProject(name string, now time.Time) bool {
+	if a.Projects == nil {
+		a.Projects = make(map[string]Project)
+	}
+
+	project, exists := a.Projects[name]
+	if !exists {
+		project = Project{
+			Name:      name,
+			CreatedAt: now,
+			CheckIns:  []time.Time{},
+		}
+	}
+	project.ArchivedAt = time.Time{}
+	a.Projects[name] = project
+
+	a.ActiveProject = name
+	return !exists
+}
+
+// transferProject moves from's active project, with its full check-in
+// history, to the recipient. It returns the project's name. The caller must
+// hold dbMu for writing.
+func transferProject(from, to *discordgo.User, now time.Time) (string, error) {
+	sender, exists, err := activityFor(from.ID)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", fmt.Errorf("you don't have any projects to transfer")
+	}
+	name := sender.activeProjectName()
+	project, exists := sender.Projects[name]
+	if !exists {
+		return "", fmt.Errorf("you haven't checked in on **%s** yet, so there's nothing to transfer", name)
+	}
+
+	recipient, err := getOrCreateActivity(to.ID, to.Username)
+	if err != nil {
+		return "", fmt.Errorf("couldn't load <@%s>'s data right now, please try again later", to.ID)
+	}
+	if _, taken := recipient.Projects[name]; taken {
+		return "", fmt.Errorf("<@%s> already has a project called **%s**", to.ID, name)
+	}
+
+	project.Transfers = append(project.Transfers, ProjectTransfer{
+		FromUserID: from.ID,
+		ToUserID:   to.ID,
+		At:         now,
+	})
+	if recipient.Projects == nil {
+		recipient.Projects = make(map[string]Project)
+	}
+	recipient.Projects[name] = project
+
+	// The sender falls back to the default project
+	delete(sender.Projects, name)
+	sender.ActiveProject = ""
+
+	storeActivity(sender)
+	storeActivity(recipient)
+	return name, nil
+}
+
+// recordProjectCheckIn attributes a check-in to the active project. It
+// reports whether the check-in fell outside the project's check-in window.
+func (a *UserActivity) recordProjectCheckIn(now time.Time) bool {
+	name := a.activeProjectName()
+	a.switchProject(name, now)
+
+	project := a.Projects[name]
+	project.LastCheckIn = now
+	project.CheckIns = append(project.CheckIns, now)
+
+	// Same cap as the user-level history
+	if len(project.CheckIns) > maxCheckIns {
+		project.CheckIns = project.CheckIns[len(project.CheckIns)-maxCheckIns:]
+	}
+
+	// Outside the window still counts, but is flagged
+	outsideWindow := project.Window != nil && !project.Window.contains(now)
+	if outsideWindow {
+		project.OutsideWindowCheckIns++
+	}
+
+	a.Projects[name] = project
+	return outsideWindow
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// After this long without a check-in, regular reminders stop and the user
+// gets a single re-engagement DM instead.
+const absenceThreshold = 14 * 24 * time.Hour
+
+// How long "Pause for a month" silences reminders
+const pauseDuration = 30 * 24 * time.Hour
+
+// Custom IDs of the re-engagement buttons
+const (
+	reengageResumeID  = "reengage:resume"
+	reengagePauseID   = "reengage:pause"
+	reengageArchiveID = "reengage:archive"
+)
+
+// isAbsent reports whether the user has been silent long enough to get the
+// re-engagement flow instead of reminders.
+func (a *ReminderState) isAbsent(now time.Time) bool {
+	since := a.LastCheckIn
+	if a.AbsenceResetAt.After(since) {
+		since = a.AbsenceResetAt
+	}
+	return now.Sub(since) > absenceThreshold
+}
+
+// isPaused reports whether reminders are currently switched off for the user,
+// either because they paused, archived their active project or are taking a
+// rest day.
+func (a *ReminderState) isPaused(now time.Time) bool {
+	return now.Before(a.PausedUntil) || a.RestDay == dayKey(now) || a.Archived
+}
+
+// clearAbsence resets the re-engagement state and reminder backoff once the
+// user is back.
+func (a *UserActivity) clearAbsence() {
+	a.IgnoredReminders = 0
+	a.ReengagementSentAt = time.Time{}
+	a.PausedUntil = time.Time{}
+	a.AbsenceResetAt = time.Time{}
+}
+
+func sendReengagement(s Session, activity ReminderState) error {
+	channel, err := s.UserChannelCreate(activity.UserID)
+	if err != nil {
+		return fmt.Errorf("opening DM: %w", err)
+	}
+
+	opening := fmt.Sprintf("👋 Hi %s, it's been %d days since your last check-in on **%s**.",
+		activity.Username, int(time.Since(activity.LastCheckIn).Hours()/24), activity.Project)
+	if activity.LastCheckIn.IsZero() {
+		opening = fmt.Sprintf("👋 Hi %s, it's been %d days since you set up **%s** and I haven't seen a check-in yet.",
+			activity.Username, int(time.Since(activity.AbsenceResetAt).Hours()/24), activity.Project)
+	}
+	content := opening + " No pressure, life happens! I'll stop the daily reminders for now. What would you like to do?"
+
+	_, err = s.ChannelMessageSendComplex(channel.ID, &discordgo.MessageSend{
+		Content: content,
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{Label: "Resume project", Style: discordgo.SuccessButton, CustomID: reengageResumeID},
+					discordgo.Button{Label: "Pause for a month", Style: discordgo.PrimaryButton, CustomID: reengagePauseID},
+					discordgo.Button{Label: "Archive project", Style: discordgo.SecondaryButton, CustomID: reengageArchiveID},
+				},
+			},
+		},
+	})
+	return err
+}
+
+func handleReengagementButton(s Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	now := time.Now()
+
+	dbMu.Lock()
+	activity, exists, err := activityFor(user.ID)
+	if err != nil || !exists {
+		dbMu.Unlock()
+		if err != nil {
+			respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+		}
+		return
+	}
+
+	var reply string
+	switch i.MessageComponentData().CustomID {
+	case reengageResumeID:
+		activity.clearAbsence()
+		activity.AbsenceResetAt = now
+		reply = "💪 Welcome back! Reminders are on again. Post an update whenever you're ready."
+	case reengagePauseID:
+		activity.clearAbsence()
+		activity.PausedUntil = now.Add(pauseDuration)
+		activity.AbsenceResetAt = activity.PausedUntil
+		reply = fmt.Sprintf("⏸️ Paused until %s. Check in any time to pick things back up early.", activity.PausedUntil.Format("Jan 2"))
+	case reengageArchiveID:
+		activity.clearAbsence()
+		name := activity.activeProjectName()
+		activity.switchProject(name, now)
+		project := activity.Projects[name]
+		project.ArchivedAt = now
+		activity.Projects[name] = project
+		reply = fmt.Sprintf("📦 Archived **%s**. I won't remind you about it; checking in again will bring it back.", name)
+	}
+	storeActivity(activity)
+	dbMu.Unlock()
+	requestSave()
+
+	// Replace the buttons with the outcome so they can't be pressed twice
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    reply,
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+	log.Printf("%s chose %s", user.Username, i.MessageComponentData().CustomID)
+}
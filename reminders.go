@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Reminders go out within this long after their slot
+const reminderGrace = 5 * time.Minute
+
+// How far apart reminders are as they keep being ignored: daily at first,
+// then every 3 days, then weekly. Each step lasts Reminders reminders and the
+// last one repeats. The steps have to be short enough to reach the weekly one
+// before the user counts as absent (absenceThreshold): the first reminder
+// comes a day or two after the last check-in, so the first weekly one falls
+// on day 12 or 13.
+var reminderBackoff = []struct{ Days, Reminders int }{
+	{Days: 1, Reminders: 2},
+	{Days: 3, Reminders: 1},
+	{Days: 7},
+}
+
+// What a user is due at a reminder check
+type reminderAction int
+
+const (
+	reminderNone reminderAction = iota
+	reminderOverdue
+	reminderReengagement
+)
+
+// What the reminder routine needs to know about a user. One is kept for every
+// user in bot.json and refreshed by storeActivity, so finding out who's due
+// never means reading user files.
+type ReminderState struct {
+	UserID   string         `json:"userID"`
+	Username string         `json:"username"`
+	Project  string         `json:"project"`            // Active project
+	Window   *CheckInWindow `json:"window,omitempty"`   // The active project's check-in window
+	Archived bool           `json:"archived,omitempty"` // Whether the active project is archived
+
+	LastCheckIn        time.Time `json:"lastCheckIn,omitzero"`
+	LastReminderAt     time.Time `json:"lastReminderAt,omitzero"`
+	IgnoredReminders   int       `json:"ignoredReminders,omitempty"`
+	ReengagementSentAt time.Time `json:"reengagementSentAt,omitzero"`
+	PausedUntil        time.Time `json:"pausedUntil,omitzero"`
+	AbsenceResetAt     time.Time `json:"absenceResetAt,omitzero"`
+	RestDay            string    `json:"restDay,omitempty"`
+}
+
+// reminderState summarizes the activity for the reminder index.
+func (a *UserActivity) reminderState() ReminderState {
+	name := a.activeProjectName()
+	project := a.Projects[name]
+	return ReminderState{
+		UserID:   a.UserID,
+		Username: a.Username,
+		Project:  name,
+		Window:   project.Window,
+		Archived: !project.ArchivedAt.IsZero(),
+
+		LastCheckIn:        a.LastCheckIn,
+		LastReminderAt:     a.LastReminderAt,
+		IgnoredReminders:   a.IgnoredReminders,
+		ReengagementSentAt: a.ReengagementSentAt,
+		PausedUntil:        a.PausedUntil,
+		AbsenceResetAt:     a.AbsenceResetAt,
+		RestDay:            a.RestDay,
+	}
+}
+
+// reminderIntervalDays returns how many days apart the user's reminders
+// should be, given how many have gone unanswered.
+func (a *ReminderState) reminderIntervalDays() int {
+	ignored := a.IgnoredReminders
+	for _, step := range reminderBackoff[:len(reminderBackoff)-1] {
+		if ignored < step.Reminders {
+			return step.Days
+		}
+		ignored -= step.Reminders
+	}
+	return reminderBackoff[len(reminderBackoff)-1].Days
+}
+
+// backingOff reports whether the user was reminded too recently to be
+// reminded again on now's day.
+func (a *ReminderState) backingOff(now time.Time) bool {
+	if a.LastReminderAt.IsZero() {
+		return false
+	}
+
+	// Count calendar days, rounding so DST changes don't matter
+	lastDay := atClockTime(a.LastReminderAt, "00:00")
+	today := atClockTime(now, "00:00")
+	days := int(today.Sub(lastDay).Hours()/24 + 0.5)
+	return days < a.reminderIntervalDays()
+}
+
+func reminderRoutine(s Session) {
+	// Check every minute so no reminder slot is missed
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		checkAndSendReminders(s)
+	}
+}
+
+// reminderSlot returns when the user's reminder is due on now's day and
+// whether they will be overdue by then. Projects with a check-in window are
+// nudged when the window closes if nothing came in during it; everyone else
+// at the configured reminder time once checkInFrequency hours have passed.
+func (a *ReminderState) reminderSlot(now time.Time) (slot time.Time, overdue bool) {
+	if a.Window != nil {
+		start, end := a.Window.bounds(now)
+		return end, a.LastCheckIn.Before(start)
+	}
+
+	slot = atClockTime(now, config.ReminderTime)
+	return slot, now.Sub(a.LastCheckIn) > time.Duration(config.CheckInFrequency)*time.Hour
+}
+
+// due decides what, if anything, should be sent to the user at now.
+func (a *ReminderState) due(now time.Time) reminderAction {
+	slot, isOverdue := a.reminderSlot(now)
+
+	// Only act in the few minutes after the slot, and only once per slot
+	if now.Before(slot) || now.Sub(slot) > reminderGrace || !a.LastReminderAt.Before(slot) {
+		return reminderNone
+	}
+
+	if a.isPaused(now) {
+		return reminderNone
+	}
+
+	// Long absences get a single re-engagement DM instead of reminders
+	if a.isAbsent(now) {
+		if a.ReengagementSentAt.IsZero() {
+			return reminderReengagement
+		}
+		return reminderNone
+	}
+
+	// If user hasn't checked in within the frequency period or window,
+	// unless they've been ignoring reminders and it's not time for the next
+	if isOverdue && !a.backingOff(now) {
+		return reminderOverdue
+	}
+	return reminderNone
+}
+
+func checkAndSendReminders(s Session) {
+	now := time.Now()
+
+	// Check all users for overdue check-ins
+	var overdue, absent []ReminderState
+	dbMu.RLock()
+	for _, activity := range database.Reminders {
+		switch activity.due(now) {
+		case reminderOverdue:
+			overdue = append(overdue, activity)
+		case reminderReengagement:
+			absent = append(absent, activity)
+		}
+	}
+	dbMu.RUnlock()
+
+	// Send outside the lock so slow Discord calls don't block check-ins
+	for _, activity := range overdue {
+		hoursOverdue := int(now.Sub(activity.LastCheckIn).Hours())
+		if activity.LastCheckIn.IsZero() {
+			hoursOverdue = 0
+		}
+		err := sendReminder(s, activity.UserID, hoursOverdue)
+		if err != nil {
+			log.Printf("Error sending reminder to %s: %v", activity.Username, err)
+			continue
+		}
+		markReminded(activity.UserID, now, false)
+		publish(context.Background(), events, ReminderSent{UserID: activity.UserID, Username: activity.Username, HoursOverdue: hoursOverdue})
+	}
+
+	for _, activity := range absent {
+		err := sendReengagement(s, activity)
+		if err != nil {
+			log.Printf("Error sending re-engagement DM to %s: %v", activity.Username, err)
+			continue
+		}
+		markReminded(activity.UserID, now, true)
+		publish(context.Background(), events, ReminderSent{UserID: activity.UserID, Username: activity.Username, Reengagement: true})
+	}
+}
+
+// markReminded records that a reminder, or the re-engagement DM, went out.
+func markReminded(userID string, now time.Time, reengagement bool) {
+	dbMu.Lock()
+	activity, exists, err := activityFor(userID)
+	if err != nil || !exists {
+		dbMu.Unlock()
+		log.Printf("Couldn't record reminder for user %s: %v", userID, err)
+		return
+	}
+	activity.recordReminder(now, reengagement)
+	storeActivity(activity)
+	dbMu.Unlock()
+	requestSave()
+}
+
+// recordReminder notes a reminder, or the re-engagement DM, sent at now.
+func (a *UserActivity) recordReminder(now time.Time, reengagement bool) {
+	a.LastReminderAt = now
+	if reengagement {
+		a.ReengagementSentAt = now
+	} else {
+		a.IgnoredReminders++
+	}
+}
+
+// sendReminder posts a reminder where check-ins are expected. A zero
+// hoursSinceLastCheckIn means the user hasn't checked in yet.
+func sendReminder(s Session, userID string, hoursSinceLastCheckIn int) error {
+	message := fmt.Sprintf("📚 Hey <@%s>! It's been %d hours since your last study check-in. How's your progress going today?", userID, hoursSinceLastCheckIn)
+	if hoursSinceLastCheckIn == 0 {
+		message = fmt.Sprintf("📚 Hey <@%s>! You haven't checked in yet. How's your progress going today?", userID)
+	}
+
+	_, err := s.ChannelMessageSend(checkInChannelID(), message)
+	return err
+}
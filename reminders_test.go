@@ -0,0 +1,68 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestReminderBackoff follows a user who stops checking in through the days
+// after their last check-in, running the reminder check just after the
+// reminder time each day.
+func TestReminderBackoff(t *testing.T) {
+	config.ReminderTime = "09:00"
+	config.CheckInFrequency = 24
+
+	tests := []struct {
+		name            string
+		lastCheckIn     string // Clock time on day 0
+		reminderDays    []int
+		reengagementDay int
+	}{
+		{"checked in before reminder time", "08:00", []int{1, 2, 5, 12}, 14},
+		{"checked in after reminder time", "20:00", []int{2, 3, 6, 13}, 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day0 := time.Date(2024, 3, 4, 0, 0, 0, 0, time.Local)
+			activity := UserActivity{UserID: "1", LastCheckIn: atClockTime(day0, tt.lastCheckIn)}
+
+			var reminderDays []int
+			reengagementDay := 0
+			for day := 1; day <= 28; day++ {
+				now := atClockTime(day0.AddDate(0, 0, day), config.ReminderTime).Add(time.Minute)
+				state := activity.reminderState()
+				switch state.due(now) {
+				case reminderOverdue:
+					reminderDays = append(reminderDays, day)
+					activity.recordReminder(now, false)
+				case reminderReengagement:
+					if reengagementDay != 0 {
+						t.Errorf("second re-engagement DM on day %d", day)
+					}
+					reengagementDay = day
+					activity.recordReminder(now, true)
+				}
+			}
+
+			if !slices.Equal(reminderDays, tt.reminderDays) {
+				t.Errorf("reminders on days %v, want %v", reminderDays, tt.reminderDays)
+			}
+			if reengagementDay != tt.reengagementDay {
+				t.Errorf("re-engagement DM on day %d, want %d", reengagementDay, tt.reengagementDay)
+			}
+
+			// Every step of the backoff is reached before the user is absent
+			intervals := make(map[int]bool)
+			for i := 1; i < len(reminderDays); i++ {
+				intervals[reminderDays[i]-reminderDays[i-1]] = true
+			}
+			for _, step := range reminderBackoff {
+				if !intervals[step.Days] {
+					t.Errorf("no reminders %d day(s) apart in %v", step.Days, reminderDays)
+				}
+			}
+		})
+	}
+}
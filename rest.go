@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Check-ins between these times of the bot's local time are late at night.
+// A night is named after the evening it starts on, so 01:00 belongs to the
+// day before.
+const (
+	lateNightStart = "23:00"
+	lateNightEnd   = "05:00"
+)
+
+// After this many late nights in a row the user is nudged to rest, at most
+// once per restNudgeCooldown
+const (
+	lateNightsBeforeNudge = 5
+	restNudgeCooldown     = 7 * 24 * time.Hour
+)
+
+// Custom IDs of the rest nudge buttons
+const (
+	restScheduleID = "rest:schedule"
+	restDismissID  = "rest:dismiss"
+)
+
+// isLateNight reports whether t falls in the late-night hours.
+func isLateNight(t time.Time) bool {
+	return !t.Before(atClockTime(t, lateNightStart)) || t.Before(atClockTime(t, lateNightEnd))
+}
+
+// nightOf returns the day whose evening t's night started on.
+func nightOf(t time.Time) time.Time {
+	if t.Before(atClockTime(t, lateNightEnd)) {
+		return t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// trackLateNight counts a check-in at now towards the user's run of
+// consecutive late nights. It returns the length of the run, or 0 if the
+// check-in wasn't late.
+func (a *UserActivity) trackLateNight(now time.Time) int {
+	if !isLateNight(now) {
+		return 0
+	}
+
+	night := nightOf(now)
+	switch a.LastLateNight {
+	case dayKey(night):
+		// Already counted tonight
+	case dayKey(night.AddDate(0, 0, -1)):
+		a.LateNights++
+	default:
+		a.LateNights = 1
+	}
+	a.LastLateNight = dayKey(night)
+	return a.LateNights
+}
+
+// suggestRest privately nudges users who keep checking in late at night to
+// take a break.
+func suggestRest(ctx context.Context, s Session, e CheckInRecorded) {
+	if e.LateNights < lateNightsBeforeNudge {
+		return
+	}
+
+	dbMu.Lock()
+	activity, exists, err := activityFor(e.UserID)
+	dbMu.Unlock()
+	if err != nil || !exists || e.At.Sub(activity.RestNudgeSentAt) < restNudgeCooldown {
+		return
+	}
+
+	// Send outside the lock, and only start the cooldown once it's delivered
+	err = sendRestNudge(ctx, s, e)
+	if err != nil {
+		log.Printf("Error sending rest nudge to %s: %v", e.Username, err)
+		return
+	}
+	markRestNudged(e.UserID, e.At)
+	log.Printf("Suggested a rest day to %s after %d late nights", e.Username, e.LateNights)
+}
+
+// markRestNudged records that the rest nudge went out.
+func markRestNudged(userID string, at time.Time) {
+	dbMu.Lock()
+	activity, exists, err := activityFor(userID)
+	if err != nil || !exists {
+		dbMu.Unlock()
+		log.Printf("Couldn't record rest nudge for user %s: %v", userID, err)
+		return
+	}
+	activity.RestNudgeSentAt = at
+	storeActivity(activity)
+	dbMu.Unlock()
+	requestSave()
+}
+
+func sendRestNudge(ctx context.Context, s Session, e CheckInRecorded) error {
+	channel, err := s.UserChannelCreate(e.UserID, discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("opening DM: %w", err)
+	}
+
+	content := fmt.Sprintf("🌙 Hi %s, you've checked in late at night %d nights in a row. "+
+		"The dedication is great, but so is sleep! How about a rest day? It won't break your %d-day streak.",
+		e.Username, e.LateNights, e.Streak)
+
+	_, err = s.ChannelMessageSendComplex(channel.ID, &discordgo.MessageSend{
+		Content: content,
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{Label: "Take a rest day", Style: discordgo.SuccessButton, CustomID: restScheduleID},
+					discordgo.Button{Label: "I'm fine", Style: discordgo.SecondaryButton, CustomID: restDismissID},
+				},
+			},
+		},
+	}, discordgo.WithContext(ctx))
+	return err
+}
+
+func handleRestButton(s Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	now := time.Now()
+
+	var reply string
+	switch i.MessageComponentData().CustomID {
+	case restScheduleID:
+		// The day after tonight: today if it's already past midnight
+		restDay := nightOf(now).AddDate(0, 0, 1)
+
+		dbMu.Lock()
+		activity, exists, err := activityFor(user.ID)
+		if err != nil || !exists {
+			dbMu.Unlock()
+			if err != nil {
+				respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+			}
+			return
+		}
+		activity.RestDay = dayKey(restDay)
+		storeActivity(activity)
+		dbMu.Unlock()
+		requestSave()
+
+		reply = fmt.Sprintf("😴 Enjoy your rest on %s! Your streak is safe and I won't send reminders that day.", restDay.Format("Monday, Jan 2"))
+	case restDismissID:
+		reply = "👍 Got it. Take care of yourself!"
+	}
+
+	// Replace the buttons with the outcome so they can't be pressed twice
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    reply,
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+	log.Printf("%s chose %s", user.Username, i.MessageComponentData().CustomID)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// A Discord call made through fakeSession
+type fakeCall struct {
+	Method    string
+	ChannelID string
+	MessageID string
+	Content   string // Message content, emoji for reactions
+	Data      any    // The full payload, for calls that take one
+}
+
+// fakeSession is a Session that records every call instead of talking to
+// Discord. Calls succeed with plausible results unless their method is in
+// fail.
+type fakeSession struct {
+	mu       sync.Mutex
+	calls    []fakeCall
+	fail     map[string]error
+	nextID   int
+	messages map[string]*discordgo.Message // What ChannelMessage finds, by ID
+}
+
+var _ Session = (*fakeSession)(nil)
+
+func newFakeSession() *fakeSession {
+	return &fakeSession{fail: make(map[string]error), messages: make(map[string]*discordgo.Message)}
+}
+
+// record notes a call and returns the error configured for its method. Like
+// discordgo, the call fails if it was given a context that's done.
+func (f *fakeSession) record(call fakeCall, options ...discordgo.RequestOption) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, call)
+	f.nextID++
+	id := fmt.Sprintf("fake-%d", f.nextID)
+
+	cfg := &discordgo.RequestConfig{Request: httptest.NewRequest("GET", "/", nil)}
+	for _, option := range options {
+		option(cfg)
+	}
+	if err := cfg.Request.Context().Err(); err != nil {
+		return id, err
+	}
+	return id, f.fail[call.Method]
+}
+
+// callsTo returns the calls made to method so far, oldest first.
+func (f *fakeSession) callsTo(method string) []fakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var calls []fakeCall
+	for _, call := range f.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// waitFor waits for a call to method, for handlers that finish in the
+// background, and returns it.
+func (f *fakeSession) waitFor(t *testing.T, method string) fakeCall {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if calls := f.callsTo(method); len(calls) > 0 {
+			return calls[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no call to %s", method)
+	return fakeCall{}
+}
+
+func (f *fakeSession) User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error) {
+	_, err := f.record(fakeCall{Method: "User", Content: userID}, options...)
+	return &discordgo.User{ID: userID, Username: "user" + userID, Bot: userID == "@me"}, err
+}
+
+func (f *fakeSession) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	_, err := f.record(fakeCall{Method: "UserChannelCreate", Content: recipientID}, options...)
+	return &discordgo.Channel{ID: "dm-" + recipientID, Type: discordgo.ChannelTypeDM}, err
+}
+
+func (f *fakeSession) Application(appID string) (*discordgo.Application, error) {
+	_, err := f.record(fakeCall{Method: "Application"})
+	return &discordgo.Application{ID: appID, Flags: applicationFlagGatewayMessageContent}, err
+}
+
+func (f *fakeSession) ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error) {
+	_, err := f.record(fakeCall{Method: "ApplicationCommandBulkOverwrite", Data: commands}, options...)
+	return commands, err
+}
+
+func (f *fakeSession) Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	_, err := f.record(fakeCall{Method: "Channel", ChannelID: channelID}, options...)
+	return &discordgo.Channel{ID: channelID, Name: "channel", Type: discordgo.ChannelTypeGuildText}, err
+}
+
+func (f *fakeSession) ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	_, err := f.record(fakeCall{Method: "ChannelMessage", ChannelID: channelID, MessageID: messageID}, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	message, ok := f.messages[messageID]
+	if !ok {
+		return nil, fmt.Errorf("unknown message %s", messageID)
+	}
+	return message, nil
+}
+
+func (f *fakeSession) ChannelEdit(channelID string, data *discordgo.ChannelEdit, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	_, err := f.record(fakeCall{Method: "ChannelEdit", ChannelID: channelID, Data: data}, options...)
+	return &discordgo.Channel{ID: channelID}, err
+}
+
+func (f *fakeSession) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	id, err := f.record(fakeCall{Method: "ChannelMessageSend", ChannelID: channelID, Content: content}, options...)
+	return &discordgo.Message{ID: id, ChannelID: channelID, Content: content}, err
+}
+
+func (f *fakeSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	id, err := f.record(fakeCall{Method: "ChannelMessageSendComplex", ChannelID: channelID, Content: data.Content, Data: data}, options...)
+	return &discordgo.Message{ID: id, ChannelID: channelID, Content: data.Content}, err
+}
+
+func (f *fakeSession) ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	_, err := f.record(fakeCall{Method: "ChannelMessageEdit", ChannelID: channelID, MessageID: messageID, Content: content}, options...)
+	return &discordgo.Message{ID: messageID, ChannelID: channelID, Content: content}, err
+}
+
+func (f *fakeSession) ChannelMessagePin(channelID, messageID string, options ...discordgo.RequestOption) error {
+	_, err := f.record(fakeCall{Method: "ChannelMessagePin", ChannelID: channelID, MessageID: messageID}, options...)
+	return err
+}
+
+func (f *fakeSession) MessageReactionAdd(channelID, messageID, emojiID string, options ...discordgo.RequestOption) error {
+	_, err := f.record(fakeCall{Method: "MessageReactionAdd", ChannelID: channelID, MessageID: messageID, Content: emojiID}, options...)
+	return err
+}
+
+func (f *fakeSession) MessageThreadStartComplex(channelID, messageID string, data *discordgo.ThreadStart, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	id, err := f.record(fakeCall{Method: "MessageThreadStartComplex", ChannelID: channelID, MessageID: messageID, Content: data.Name, Data: data}, options...)
+	return &discordgo.Channel{ID: id, ParentID: channelID, Name: data.Name, Type: discordgo.ChannelTypeGuildPublicThread}, err
+}
+
+func (f *fakeSession) UserChannelPermissions(userID, channelID string, fetchOptions ...discordgo.RequestOption) (int64, error) {
+	_, err := f.record(fakeCall{Method: "UserChannelPermissions", ChannelID: channelID, Content: userID}, fetchOptions...)
+	return discordgo.PermissionAll, err
+}
+
+func (f *fakeSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	call := fakeCall{Method: "InteractionRespond", ChannelID: interaction.ChannelID, Data: resp}
+	if resp.Data != nil {
+		call.Content = resp.Data.Content
+	}
+	_, err := f.record(call, options...)
+	return err
+}
+
+func (f *fakeSession) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	call := fakeCall{Method: "InteractionResponseEdit", ChannelID: interaction.ChannelID, Data: newresp}
+	if newresp.Content != nil {
+		call.Content = *newresp.Content
+	}
+	id, err := f.record(call, options...)
+	return &discordgo.Message{ID: id, ChannelID: interaction.ChannelID, Content: call.Content}, err
+}
+
+func (f *fakeSession) InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error {
+	_, err := f.record(fakeCall{Method: "InteractionResponseDelete", ChannelID: interaction.ChannelID}, options...)
+	return err
+}
+
+func (f *fakeSession) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	id, err := f.record(fakeCall{Method: "FollowupMessageCreate", ChannelID: interaction.ChannelID, Content: data.Content, Data: data}, options...)
+	return &discordgo.Message{ID: id, ChannelID: interaction.ChannelID, Content: data.Content}, err
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// dayKey identifies a local calendar day.
+func dayKey(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// weekKey identifies an ISO week.
+func weekKey(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// updateStreak counts a check-in at now towards the user's daily streak and
+// weekly total. A streak is the number of consecutive days with at least one
+// check-in, not counting a scheduled rest day.
+func (a *UserActivity) updateStreak(now time.Time) {
+	today := dayKey(now)
+	previous := now.AddDate(0, 0, -1)
+	if a.RestDay == dayKey(previous) && a.LastStreakDay != a.RestDay {
+		previous = previous.AddDate(0, 0, -1)
+	}
+
+	switch a.LastStreakDay {
+	case today:
+		// Already counted today
+	case dayKey(previous):
+		a.CurrentStreak++
+	default:
+		a.CurrentStreak = 1
+	}
+	a.LastStreakDay = today
+
+	if a.CurrentStreak > a.LongestStreak {
+		a.LongestStreak = a.CurrentStreak
+	}
+
+	week := weekKey(now)
+	if a.Week != week {
+		a.Week = week
+		a.WeekCheckIns = 0
+	}
+	a.WeekCheckIns++
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Longest auto-archive Discord allows, in minutes, so a thread stays open
+// for its whole week even when it's quiet
+const weeklyThreadArchiveMinutes = 7 * 24 * 60
+
+// Permissions the bot needs in the study channel for weekly threads: start
+// them, archive them and post reminders in them
+const weeklyThreadPermissions = discordgo.PermissionCreatePublicThreads |
+	discordgo.PermissionManageThreads |
+	discordgo.PermissionSendMessagesInThreads
+
+// The thread check-ins are directed to this week
+type WeeklyThread struct {
+	ID   string `json:"id"`
+	Week string `json:"week"` // ISO week, see weekKey
+
+	// A posted announcement whose thread couldn't be started yet. Retries
+	// start the thread from it instead of announcing the week again.
+	AnnouncementID   string `json:"announcementID,omitempty"`
+	AnnouncementWeek string `json:"announcementWeek,omitempty"`
+}
+
+// weekStart returns midnight on the Monday of t's week.
+func weekStart(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, t.Location())
+}
+
+// checkInChannelID returns where check-ins are expected and reminders go:
+// this week's thread if weekly threads are on and it exists, otherwise the
+// study channel.
+func checkInChannelID() string {
+	if config.WeeklyThreads {
+		dbMu.RLock()
+		thread := database.WeeklyThread
+		dbMu.RUnlock()
+		if thread.ID != "" && thread.Week == weekKey(time.Now()) {
+			return thread.ID
+		}
+	}
+	return config.StudyChannelID
+}
+
+// isCheckInChannel reports whether activity in channelID can count as a
+// check-in.
+func isCheckInChannel(channelID string) bool {
+	return channelID == config.StudyChannelID || channelID == checkInChannelID()
+}
+
+func weeklyThreadRoutine(s Session) {
+	rollWeeklyThread(s)
+
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		rollWeeklyThread(s)
+	}
+}
+
+// rollWeeklyThread archives last week's thread and starts this week's, if
+// that hasn't happened yet.
+func rollWeeklyThread(s Session) {
+	now := time.Now()
+	week := weekKey(now)
+
+	dbMu.RLock()
+	previous := database.WeeklyThread
+	dbMu.RUnlock()
+
+	if previous.Week == week {
+		return
+	}
+
+	// Start the thread from an announcement so the channel points people to
+	// it, reusing this week's if an earlier attempt got that far
+	name := "Week of " + weekStart(now).Format("Jan 2")
+	announcementID := ""
+	if previous.AnnouncementWeek == week {
+		announcementID = previous.AnnouncementID
+	}
+	if announcementID == "" {
+		announcement, err := s.ChannelMessageSend(config.StudyChannelID, fmt.Sprintf("🧵 New week! Post this week's check-ins in the **%s** thread below.", name))
+		if err != nil {
+			log.Printf("Error announcing weekly thread: %v", err)
+			return
+		}
+		announcementID = announcement.ID
+
+		dbMu.Lock()
+		database.WeeklyThread.AnnouncementID = announcementID
+		database.WeeklyThread.AnnouncementWeek = week
+		dbMu.Unlock()
+		requestSave()
+	}
+
+	thread, err := s.MessageThreadStartComplex(config.StudyChannelID, announcementID, &discordgo.ThreadStart{
+		Name:                name,
+		AutoArchiveDuration: weeklyThreadArchiveMinutes,
+	})
+	if err != nil {
+		log.Printf("Error starting weekly thread, retrying within the hour: %v", err)
+
+		// Announce again next time if the announcement was deleted
+		if isUnknownMessage(err) {
+			dbMu.Lock()
+			database.WeeklyThread.AnnouncementID = ""
+			dbMu.Unlock()
+			requestSave()
+		}
+		return
+	}
+
+	dbMu.Lock()
+	database.WeeklyThread = WeeklyThread{ID: thread.ID, Week: week}
+	dbMu.Unlock()
+	requestSave()
+	log.Printf("Started weekly thread %q", name)
+
+	if previous.ID != "" {
+		archived := true
+		_, err := s.ChannelEdit(previous.ID, &discordgo.ChannelEdit{Archived: &archived, Locked: &archived})
+		if err != nil {
+			log.Printf("Error archiving last week's thread: %v", err)
+		}
+	}
+}
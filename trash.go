@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// A deleted project waiting in the trash until it is restored or purged
+type TrashedProject struct {
+	ID        int       `json:"id"`
+	Project   Project   `json:"project"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// trashProject moves a project into the trash, freeing up its name. It
+// returns the trash ID to restore it with.
+func (a *UserActivity) trashProject(name string, now time.Time) (int, error) {
+	project, exists := a.Projects[name]
+	if !exists {
+		return 0, fmt.Errorf("you don't have a project called **%s**", name)
+	}
+
+	a.NextTrashID++
+	a.Trash = append(a.Trash, TrashedProject{
+		ID:        a.NextTrashID,
+		Project:   project,
+		DeletedAt: now,
+	})
+	delete(a.Projects, name)
+
+	// Check-ins go back to the default project
+	if a.ActiveProject == name {
+		a.ActiveProject = ""
+	}
+
+	return a.NextTrashID, nil
+}
+
+// restoreProject takes a project back out of the trash. It returns the
+// project's name.
+func (a *UserActivity) restoreProject(id int) (string, error) {
+	for idx, trashed := range a.Trash {
+		if trashed.ID != id {
+			continue
+		}
+
+		name := trashed.Project.Name
+		if _, taken := a.Projects[name]; taken {
+			return "", fmt.Errorf("you already have a project called **%s**; delete it first to restore this one", name)
+		}
+
+		if a.Projects == nil {
+			a.Projects = make(map[string]Project)
+		}
+		a.Projects[name] = trashed.Project
+		a.Trash = append(a.Trash[:idx], a.Trash[idx+1:]...)
+		return name, nil
+	}
+
+	return "", fmt.Errorf("there's nothing in your trash with ID %d", id)
+}
+
+// purgeExpired permanently removes trash older than the purge period and
+// reports how many projects were removed.
+func (a *UserActivity) purgeExpired(now time.Time) int {
+	cutoff := now.AddDate(0, 0, -config.TrashPurgeDays)
+
+	kept := a.Trash[:0]
+	for _, trashed := range a.Trash {
+		if trashed.DeletedAt.After(cutoff) {
+			kept = append(kept, trashed)
+		}
+	}
+
+	purged := len(a.Trash) - len(kept)
+	a.Trash = kept
+	return purged
+}
+
+func trashPurgeRoutine() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purgeTrash()
+	}
+}
+
+func purgeTrash() {
+	now := time.Now()
+	purged := 0
+
+	dbMu.Lock()
+	// Users that aren't loaded are purged when they're next read
+	for _, activity := range database.UserActivities {
+		if n := activity.purgeExpired(now); n > 0 {
+			purged += n
+			storeActivity(activity)
+		}
+	}
+	dbMu.Unlock()
+
+	if purged > 0 {
+		requestSave()
+		log.Printf("Purged %d project(s) from the trash", purged)
+	}
+}
+
+func handleTrashCommand(s Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	sub := i.ApplicationCommandData().Options[0]
+
+	switch sub.Name {
+	case "list":
+		dbMu.Lock()
+		activity, _, err := activityFor(user.ID)
+		trash := append([]TrashedProject(nil), activity.Trash...)
+		dbMu.Unlock()
+		if err != nil {
+			respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+			return
+		}
+
+		if len(trash) == 0 {
+			respondEphemeral(s, i, "🗑️ Your trash is empty.")
+			return
+		}
+
+		var lines []string
+		for _, trashed := range trash {
+			purgeAt := trashed.DeletedAt.AddDate(0, 0, config.TrashPurgeDays)
+			lines = append(lines, fmt.Sprintf("`%d` **%s**: %d recent check-ins, deleted %s, purged %s",
+				trashed.ID, trashed.Project.Name, len(trashed.Project.CheckIns),
+				trashed.DeletedAt.Format("Jan 2"), purgeAt.Format("Jan 2")))
+		}
+		respondEphemeral(s, i, "🗑️ Your trash (restore with `/trash restore <id>`):\n"+strings.Join(lines, "\n"))
+
+	case "restore":
+		id := int(sub.Options[0].IntValue())
+
+		dbMu.Lock()
+		activity, _, err := activityFor(user.ID)
+		var name string
+		if err == nil {
+			name, err = activity.restoreProject(id)
+		}
+		if err == nil {
+			storeActivity(activity)
+		}
+		dbMu.Unlock()
+		if err != nil {
+			respondEphemeral(s, i, fmt.Sprintf("❌ %v", err))
+			return
+		}
+		requestSave()
+
+		respondEphemeral(s, i, fmt.Sprintf("♻️ Restored **%s**. Use `/project switch %s` to check in on it again.", name, name))
+		log.Printf("%s restored project %s from the trash", user.Username, name)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Added next to the usual acknowledgment on check-ins outside the window
+const outsideWindowReaction = "🌙"
+
+// A daily span of the bot's local time in which check-ins are expected.
+// Check-ins outside it still count but are flagged, and reminders wait until
+// it has closed.
+type CheckInWindow struct {
+	Start string `json:"start"` // Format: "15:04" (24h)
+	End   string `json:"end"`   // Format: "15:04" (24h), after Start
+}
+
+// newCheckInWindow validates start and end and returns the window between them.
+func newCheckInWindow(start, end string) (*CheckInWindow, error) {
+	startHour, startMinute, err := parseClockTime(start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start time %q, expected 24h HH:MM", start)
+	}
+	endHour, endMinute, err := parseClockTime(end)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end time %q, expected 24h HH:MM", end)
+	}
+	if endHour*60+endMinute <= startHour*60+startMinute {
+		return nil, fmt.Errorf("the window has to end after it starts on the same day")
+	}
+
+	return &CheckInWindow{Start: start, End: end}, nil
+}
+
+// bounds returns when the window opens and closes on the given day.
+func (w CheckInWindow) bounds(day time.Time) (start, end time.Time) {
+	return atClockTime(day, w.Start), atClockTime(day, w.End)
+}
+
+// contains reports whether t falls inside the window on its day.
+func (w CheckInWindow) contains(t time.Time) bool {
+	start, end := w.bounds(t)
+	return !t.Before(start) && t.Before(end)
+}
+
+// atClockTime returns the time on day's date at the "HH:MM" clock time
+// value. value must already be validated.
+func atClockTime(day time.Time, value string) time.Time {
+	hour, minute, _ := parseClockTime(value)
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+}